@@ -17,11 +17,14 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -31,14 +34,24 @@ import (
 	"github.com/konflux-ci/operator-toolkit/webhook"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	routev1 "github.com/openshift/api/route/v1"
 
 	appstudiov1alpha1 "github.com/konflux-ci/application-api/api/v1alpha1"
+	"github.com/redhat-appstudio/application-service/pkg/consistency"
+	"github.com/redhat-appstudio/application-service/pkg/featuregates"
+	"github.com/redhat-appstudio/application-service/pkg/health"
+	"github.com/redhat-appstudio/application-service/pkg/labels"
+	"github.com/redhat-appstudio/application-service/pkg/metrics"
+	"github.com/redhat-appstudio/application-service/pkg/util"
 	"github.com/redhat-appstudio/application-service/webhooks"
 
 	// Enable pprof for profiling
@@ -65,8 +78,28 @@ func main() {
 	var enableLeaderElection bool
 	var probeAddr string
 	var apiExportName string
+	var metricsSecure bool
+	var metricsAuth bool
+	var metricsCertDir, metricsCertName, metricsKeyName string
+	var watchNamespaces string
+	var consistencyCheckInterval time.Duration
+	var consistencyRepair bool
 	flag.StringVar(&apiExportName, "api-export-name", "", "The name of the APIExport.")
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
+	flag.BoolVar(&metricsSecure, "metrics-secure", false, "Serve the metrics endpoint over https instead of http.")
+	flag.BoolVar(&metricsAuth, "metrics-auth", false,
+		"Authenticate and authorize requests to the metrics endpoint using TokenReviews and SubjectAccessReviews. "+
+			"Requires --metrics-secure.")
+	flag.StringVar(&metricsCertDir, "metrics-cert-dir", "",
+		"The directory containing the metrics serving certificate and key. If unset, a self-signed certificate is generated.")
+	flag.StringVar(&metricsCertName, "metrics-cert-name", "tls.crt", "The name of the metrics serving certificate file.")
+	flag.StringVar(&metricsKeyName, "metrics-key-name", "tls.key", "The name of the metrics serving key file.")
+	flag.StringVar(&watchNamespaces, "watch-namespaces", "",
+		"A comma separated list of namespaces whose resources are cached and served. If unset, all namespaces are watched.")
+	flag.DurationVar(&consistencyCheckInterval, "consistency-check-interval", 0,
+		"The interval between checks of Applications and Components for inconsistencies. If zero, no checks are run.")
+	flag.BoolVar(&consistencyRepair, "consistency-repair", false,
+		"Set missing Application owner references on Components found by the consistency checks.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
@@ -79,6 +112,31 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if err := featuregates.Default.Set(os.Getenv(featuregates.EnvVar)); err != nil {
+		setupLog.Error(err, "invalid feature gates")
+		os.Exit(1)
+	}
+	for feature, enabled := range featuregates.Default.States() {
+		setupLog.Info("feature gate", "name", feature, "enabled", enabled)
+	}
+	featuregates.Default.RecordMetrics()
+
+	// In read-only safe mode, application-service never writes to resources other than the ones under admission
+	readOnly := false
+	if value := os.Getenv("HAS_READONLY"); value != "" {
+		var err error
+		readOnly, err = strconv.ParseBool(value)
+		if err != nil {
+			setupLog.Error(err, "invalid value for HAS_READONLY, must be true or false", "value", value)
+			os.Exit(1)
+		}
+	}
+
+	if metricsAuth && !metricsSecure {
+		setupLog.Error(nil, "--metrics-auth requires --metrics-secure to be set")
+		os.Exit(1)
+	}
+
 	ctx := ctrl.SetupSignalHandler()
 
 	restConfig := ctrl.GetConfigOrDie()
@@ -93,15 +151,28 @@ func main() {
 	}
 	var mgr ctrl.Manager
 	var err error
+	managerMetricsAddr := metricsAddr
+	if metricsSecure {
+		// The metrics are served by a secure metrics server instead of the manager's plain http one
+		managerMetricsAddr = "0"
+	}
 	options := ctrl.Options{
 		Scheme:                 scheme,
-		MetricsBindAddress:     metricsAddr,
+		MetricsBindAddress:     managerMetricsAddr,
 		Port:                   9443,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "f50829e1.redhat.com",
 		LeaderElectionConfig:   restConfig,
 	}
+	if namespaces := util.SplitCommaSeparated(watchNamespaces); len(namespaces) == 1 {
+		options.Namespace = namespaces[0]
+	} else if len(namespaces) > 1 {
+		options.NewCache = cache.MultiNamespacedCacheBuilder(namespaces)
+	}
+	if watchNamespaces != "" {
+		setupLog.Info("restricting the manager to namespaces", "namespaces", watchNamespaces)
+	}
 	mgr, err = ctrl.NewManager(restConfig, options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -113,9 +184,25 @@ func main() {
 		os.Exit(1)
 	}
 
-	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+	if metricsSecure {
+		setUpSecureMetrics(mgr, restConfig, metricsAddr, metricsCertDir, metricsCertName, metricsKeyName, metricsAuth)
+	}
+
+	webhooksEnabled := os.Getenv("ENABLE_WEBHOOKS") != "false"
+	if readOnly {
+		setupLog.Info("running in read-only mode, resources other than the ones under admission will not be modified")
+	}
+	if webhooksEnabled {
 		setupLog.Info("setting up webhooks")
-		setUpWebhooks(mgr)
+		setUpWebhooks(ctx, mgr, readOnly)
+	}
+
+	if consistencyCheckInterval > 0 {
+		setUpConsistencyChecker(mgr, consistencyCheckInterval, consistencyRepair, readOnly)
+	}
+
+	if featuregates.Default.Enabled(featuregates.ComponentOwnershipLabels) {
+		setUpLabelMigrator(mgr, readOnly)
 	}
 
 	//+kubebuilder:scaffold:builder
@@ -128,6 +215,17 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	if err := mgr.AddReadyzCheck("informer-cache-sync", health.CacheSyncChecker(mgr.GetCache())); err != nil {
+		setupLog.Error(err, "unable to set up informer cache sync check")
+		os.Exit(1)
+	}
+	if webhooksEnabled {
+		// The webhook server is only reachable once its serving certificate has been loaded
+		if err := mgr.AddReadyzCheck("webhook-server", mgr.GetWebhookServer().StartedChecker()); err != nil {
+			setupLog.Error(err, "unable to set up webhook server ready check")
+			os.Exit(1)
+		}
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctx); err != nil {
@@ -136,14 +234,96 @@ func main() {
 	}
 }
 
+// setUpSecureMetrics adds a runnable to the manager that serves metrics over https, optionally requiring
+// authentication and authorization of the requests.
+func setUpSecureMetrics(mgr ctrl.Manager, restConfig *rest.Config, addr, certDir, certName, keyName string, auth bool) {
+	server := &metrics.SecureServer{
+		BindAddress: addr,
+		CertDir:     certDir,
+		CertName:    certName,
+		KeyName:     keyName,
+		Log:         ctrl.Log.WithName("metrics"),
+	}
+	if auth {
+		clientset, err := kubernetes.NewForConfig(restConfig)
+		if err != nil {
+			setupLog.Error(err, "unable to create the metrics authentication client")
+			os.Exit(1)
+		}
+		server.Auth = &metrics.DelegatingAuth{
+			TokenReviews:         clientset.AuthenticationV1().TokenReviews(),
+			SubjectAccessReviews: clientset.AuthorizationV1().SubjectAccessReviews(),
+		}
+	}
+	if err := mgr.Add(server); err != nil {
+		setupLog.Error(err, "unable to set up the secure metrics server")
+		os.Exit(1)
+	}
+}
+
+// setUpConsistencyChecker adds a runnable to the manager that periodically reports, and optionally repairs, inconsistencies
+// between Applications and Components.
+func setUpConsistencyChecker(mgr ctrl.Manager, interval time.Duration, repair, readOnly bool) {
+	checker := &consistency.Checker{
+		Client:   mgr.GetClient(),
+		Recorder: mgr.GetEventRecorderFor("consistency-checker"),
+		Log:      ctrl.Log.WithName("consistency"),
+		Interval: interval,
+		Repair:   repair,
+		ReadOnly: readOnly,
+	}
+	if err := mgr.Add(checker); err != nil {
+		setupLog.Error(err, "unable to set up the consistency checker")
+		os.Exit(1)
+	}
+}
+
+// setUpLabelMigrator adds a runnable to the manager that labels the existing Components once, as the webhook only labels
+// them when they're next admitted.
+func setUpLabelMigrator(mgr ctrl.Manager, readOnly bool) {
+	migrator := &labels.Migrator{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("labels"),
+		ReadOnly: readOnly,
+	}
+	if err := mgr.Add(migrator); err != nil {
+		setupLog.Error(err, "unable to set up the label migrator")
+		os.Exit(1)
+	}
+}
+
 // setUpWebhooks sets up webhooks.
-func setUpWebhooks(mgr ctrl.Manager) {
-	err := webhook.SetupWebhooks(mgr, webhooks.EnabledWebhooks...)
+func setUpWebhooks(ctx context.Context, mgr ctrl.Manager, readOnly bool) {
+	err := webhook.SetupWebhooks(mgr, webhooks.EnabledWebhooks(webhooks.Options{ReadOnly: readOnly})...)
 	if err != nil {
 		setupLog.Error(err, "unable to setup webhooks")
 		os.Exit(1)
 	}
 
+	// Apply the failure policy and namespace exclusions to the webhook configurations. This is done even if none are set, to
+	// remove the namespace exclusion applied by a previous run.
+	configOpts, err := webhooks.ParseConfigurationOptions(os.Getenv("WEBHOOK_FAILURE_POLICY"), os.Getenv("WEBHOOK_EXCLUDED_NAMESPACES"))
+	if err != nil {
+		setupLog.Error(err, "invalid webhook configuration options")
+		os.Exit(1)
+	}
+	configOpts.MutatingConfigurationName = os.Getenv("WEBHOOK_MUTATING_CONFIGURATION_NAME")
+	configOpts.ValidatingConfigurationName = os.Getenv("WEBHOOK_VALIDATING_CONFIGURATION_NAME")
+	if readOnly {
+		setupLog.Info("read-only mode, skipping applying the webhook configuration options", "options", configOpts)
+	} else {
+		// The manager's client can't be used before the manager is started, so use an uncached client
+		configClient, err := client.New(mgr.GetConfig(), client.Options{Scheme: mgr.GetScheme(), Mapper: mgr.GetRESTMapper()})
+		if err != nil {
+			setupLog.Error(err, "unable to create the webhook configuration client")
+			os.Exit(1)
+		}
+		if err := webhooks.ApplyConfigurationOptions(ctx, configClient, setupLog, configOpts); err != nil {
+			// Don't block startup if the webhook configurations can't be updated, but log and continue
+			setupLog.Error(err, "unable to apply the webhook configuration options")
+		}
+	}
+
 	// Retrieve the option to enable HTTP2 on the Webhook server
 	enableWebhookHTTP2 := os.Getenv("ENABLE_WEBHOOK_HTTP2")
 	if enableWebhookHTTP2 == "" {
@@ -0,0 +1,209 @@
+//
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdq
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	appstudiov1alpha1 "github.com/konflux-ci/application-api/api/v1alpha1"
+	"github.com/redhat-appstudio/application-service/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CompletedConditionType is the condition type set on a ComponentDetectionQuery once its analysis has finished
+const CompletedConditionType = "Completed"
+
+// componentNamePrefix is given to Component names that are empty or start with a digit once sanitized
+const componentNamePrefix = "comp"
+
+// ConvertOptions configures how the components detected by a ComponentDetectionQuery are converted into Components
+type ConvertOptions struct {
+	// Application is the name of the Application that the Components will belong to
+	Application string
+
+	// Namespace is the namespace the Components will be created in. Defaults to the namespace of the ComponentDetectionQuery
+	Namespace string
+
+	// Overrides maps the name of a detected component to the fields that should be set over its component stub
+	Overrides map[string]ComponentOverride
+}
+
+// ComponentOverride describes the fields of a component stub that can be overridden when converting it into a Component
+type ComponentOverride struct {
+	// Name overrides the name of the Component resource and its spec.componentName
+	Name string
+
+	// TargetPort overrides the stub's target port, if non-zero
+	TargetPort int
+
+	// Replicas overrides the stub's replica count, if set
+	Replicas *int
+
+	// Resources overrides the stub's resource requirements, if set
+	Resources *corev1.ResourceRequirements
+
+	// Env is merged into the stub's environment variables, replacing variables of the same name
+	Env []corev1.EnvVar
+}
+
+// ComponentsFromCDQ converts the components detected by a completed ComponentDetectionQuery into Components belonging to the
+// Application specified in opts. The names of the returned Components do not collide with each other or with existingNames;
+// colliding names are given a random suffix. Detected components are processed in name order, so that the first of two
+// components with the same name keeps it. An error is returned if an override doesn't match any detected component.
+func ComponentsFromCDQ(cdq *appstudiov1alpha1.ComponentDetectionQuery, opts ConvertOptions, existingNames []string) ([]appstudiov1alpha1.Component, error) {
+	if !meta.IsStatusConditionTrue(cdq.Status.Conditions, CompletedConditionType) {
+		return nil, fmt.Errorf("ComponentDetectionQuery %s has not completed successfully", cdq.Name)
+	}
+	if opts.Application == "" {
+		return nil, fmt.Errorf("an application must be specified to convert ComponentDetectionQuery %s", cdq.Name)
+	}
+
+	namespace := opts.Namespace
+	if namespace == "" {
+		namespace = cdq.Namespace
+	}
+
+	takenNames := make(map[string]bool)
+	for _, name := range existingNames {
+		takenNames[name] = true
+	}
+
+	detectedNames := make([]string, 0, len(cdq.Status.ComponentDetected))
+	for detectedName := range cdq.Status.ComponentDetected {
+		detectedNames = append(detectedNames, detectedName)
+	}
+	sort.Strings(detectedNames)
+
+	var unknownOverrides []string
+	for overrideName := range opts.Overrides {
+		if _, ok := cdq.Status.ComponentDetected[overrideName]; !ok {
+			unknownOverrides = append(unknownOverrides, overrideName)
+		}
+	}
+	if len(unknownOverrides) != 0 {
+		sort.Strings(unknownOverrides)
+		return nil, fmt.Errorf("overrides do not match any component detected by ComponentDetectionQuery %s: %s", cdq.Name, strings.Join(unknownOverrides, ", "))
+	}
+
+	var components []appstudiov1alpha1.Component
+	for _, detectedName := range detectedNames {
+		detected := cdq.Status.ComponentDetected[detectedName]
+		stub := detected.ComponentStub.DeepCopy()
+		override := opts.Overrides[detectedName]
+
+		name := stub.ComponentName
+		if override.Name != "" {
+			name = override.Name
+		}
+		if name == "" {
+			name = detectedName
+		}
+		name, err := util.GenerateUniqueName(name, componentNamePrefix, func(name string) (bool, error) {
+			return takenNames[name], nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		takenNames[name] = true
+
+		stub.ComponentName = name
+		stub.Application = opts.Application
+		applyOverride(stub, override)
+
+		components = append(components, appstudiov1alpha1.Component{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: appstudiov1alpha1.GroupVersion.String(),
+				Kind:       "Component",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Spec: *stub,
+		})
+	}
+
+	return components, nil
+}
+
+// CreateComponentsFromCDQ converts the components detected by a completed ComponentDetectionQuery into Components and creates
+// them in the cluster. Names already used by Components in the target namespace are avoided.
+func CreateComponentsFromCDQ(ctx context.Context, c client.Client, cdq *appstudiov1alpha1.ComponentDetectionQuery, opts ConvertOptions) ([]appstudiov1alpha1.Component, error) {
+	namespace := opts.Namespace
+	if namespace == "" {
+		namespace = cdq.Namespace
+	}
+
+	var componentList appstudiov1alpha1.ComponentList
+	if err := c.List(ctx, &componentList, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	var existingNames []string
+	for _, component := range componentList.Items {
+		existingNames = append(existingNames, component.Name)
+	}
+
+	components, err := ComponentsFromCDQ(cdq, opts, existingNames)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range components {
+		// Another client may have created a Component with the same name since the list, so let the create pick a new name
+		component := &components[i]
+		err := util.CreateWithUniqueName(ctx, c, component, componentNamePrefix, func(name string) {
+			component.Name = name
+			component.Spec.ComponentName = name
+		})
+		if err != nil {
+			return components[:i], fmt.Errorf("unable to create Component %s: %v", component.Name, err)
+		}
+	}
+	return components, nil
+}
+
+// applyOverride sets the non-empty fields of override over the component stub
+func applyOverride(stub *appstudiov1alpha1.ComponentSpec, override ComponentOverride) {
+	if override.TargetPort != 0 {
+		stub.TargetPort = override.TargetPort
+	}
+	if override.Replicas != nil {
+		replicas := *override.Replicas
+		stub.Replicas = &replicas
+	}
+	if override.Resources != nil {
+		stub.Resources = *override.Resources.DeepCopy()
+	}
+	for _, envVar := range override.Env {
+		replaced := false
+		for i := range stub.Env {
+			if stub.Env[i].Name == envVar.Name {
+				stub.Env[i] = envVar
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			stub.Env = append(stub.Env, envVar)
+		}
+	}
+}
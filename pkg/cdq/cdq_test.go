@@ -0,0 +1,231 @@
+//
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdq
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	appstudiov1alpha1 "github.com/konflux-ci/application-api/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestComponentsFromCDQ(t *testing.T) {
+	replicas := 3
+
+	tests := []struct {
+		name      string
+		cdq       appstudiov1alpha1.ComponentDetectionQuery
+		opts      ConvertOptions
+		wantNames []string
+		wantErr   string
+	}{
+		{
+			name: "CDQ has not completed",
+			cdq: appstudiov1alpha1.ComponentDetectionQuery{
+				ObjectMeta: metav1.ObjectMeta{Name: "cdq", Namespace: "default"},
+			},
+			opts:    ConvertOptions{Application: "application"},
+			wantErr: "has not completed successfully",
+		},
+		{
+			name:    "no application specified",
+			cdq:     completedCDQ(map[string]string{"frontend": "frontend"}),
+			wantErr: "an application must be specified",
+		},
+		{
+			name:      "components are converted in name order",
+			cdq:       completedCDQ(map[string]string{"frontend": "frontend", "backend": "backend"}),
+			opts:      ConvertOptions{Application: "application"},
+			wantNames: []string{"backend", "frontend"},
+		},
+		{
+			name:      "invalid names are sanitized",
+			cdq:       completedCDQ(map[string]string{"frontend": "My_Frontend", "backend": "1backend"}),
+			opts:      ConvertOptions{Application: "application"},
+			wantNames: []string{"comp-1backend", "my-frontend"},
+		},
+		{
+			name:      "empty stub name falls back to the detected name",
+			cdq:       completedCDQ(map[string]string{"frontend": ""}),
+			opts:      ConvertOptions{Application: "application"},
+			wantNames: []string{"frontend"},
+		},
+		{
+			name: "override name is used",
+			cdq:  completedCDQ(map[string]string{"frontend": "frontend"}),
+			opts: ConvertOptions{
+				Application: "application",
+				Overrides: map[string]ComponentOverride{
+					"frontend": {Name: "web", TargetPort: 8081, Replicas: &replicas},
+				},
+			},
+			wantNames: []string{"web"},
+		},
+		{
+			name: "override does not match a detected component",
+			cdq:  completedCDQ(map[string]string{"frontend": "frontend"}),
+			opts: ConvertOptions{
+				Application: "application",
+				Overrides: map[string]ComponentOverride{
+					"frontend": {Name: "web"},
+					"fronted":  {TargetPort: 8081},
+					"backend":  {TargetPort: 8080},
+				},
+			},
+			wantErr: "overrides do not match any component detected by ComponentDetectionQuery cdq: backend, fronted",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			components, err := ComponentsFromCDQ(&tt.cdq, tt.opts, nil)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			var names []string
+			for _, component := range components {
+				assert.Equal(t, component.Name, component.Spec.ComponentName)
+				assert.Equal(t, tt.opts.Application, component.Spec.Application)
+				assert.Equal(t, tt.cdq.Namespace, component.Namespace)
+				names = append(names, component.Name)
+			}
+			assert.Equal(t, tt.wantNames, names)
+		})
+	}
+}
+
+func TestComponentsFromCDQNameCollisions(t *testing.T) {
+	cdq := completedCDQ(map[string]string{"frontend": "web", "backend": "web", "database": "database"})
+	components, err := ComponentsFromCDQ(&cdq, ConvertOptions{Application: "application"}, []string{"database"})
+	require.NoError(t, err)
+	require.Len(t, components, 3)
+
+	// backend is processed before frontend, so it keeps the name
+	assert.Equal(t, "web", components[0].Name)
+	assert.NotEqual(t, "database", components[1].Name)
+	assert.True(t, strings.HasPrefix(components[1].Name, "database-"), "expected a suffixed name, got %s", components[1].Name)
+	assert.NotEqual(t, "web", components[2].Name)
+	assert.True(t, strings.HasPrefix(components[2].Name, "web-"), "expected a suffixed name, got %s", components[2].Name)
+}
+
+func TestApplyOverride(t *testing.T) {
+	replicas := 2
+	stub := appstudiov1alpha1.ComponentSpec{
+		TargetPort: 8080,
+		Env: []corev1.EnvVar{
+			{Name: "FOO", Value: "foo"},
+			{Name: "BAR", Value: "bar"},
+		},
+	}
+	override := ComponentOverride{
+		TargetPort: 3000,
+		Replicas:   &replicas,
+		Env: []corev1.EnvVar{
+			{Name: "BAR", Value: "override"},
+			{Name: "BAZ", Value: "baz"},
+		},
+	}
+
+	applyOverride(&stub, override)
+
+	assert.Equal(t, 3000, stub.TargetPort)
+	assert.Equal(t, 2, *stub.Replicas)
+	assert.Equal(t, []corev1.EnvVar{
+		{Name: "FOO", Value: "foo"},
+		{Name: "BAR", Value: "override"},
+		{Name: "BAZ", Value: "baz"},
+	}, stub.Env)
+
+	// Changing the override afterwards must not change the stub
+	replicas = 5
+	assert.Equal(t, 2, *stub.Replicas)
+}
+
+func TestCreateComponentsFromCDQ(t *testing.T) {
+	s := scheme.Scheme
+	err := appstudiov1alpha1.AddToScheme(s)
+	require.NoError(t, err)
+
+	existing := appstudiov1alpha1.Component{
+		ObjectMeta: metav1.ObjectMeta{Name: "frontend", Namespace: "default"},
+		Spec: appstudiov1alpha1.ComponentSpec{
+			ComponentName: "frontend",
+			Application:   "other-application",
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithObjects(&existing).Build()
+
+	cdq := completedCDQ(map[string]string{"frontend": "frontend"})
+	components, err := CreateComponentsFromCDQ(context.Background(), fakeClient, &cdq, ConvertOptions{Application: "application"})
+	require.NoError(t, err)
+	require.Len(t, components, 1)
+	assert.True(t, strings.HasPrefix(components[0].Name, "frontend-"), "expected a suffixed name, got %s", components[0].Name)
+	assert.Equal(t, components[0].Name, components[0].Spec.ComponentName)
+
+	var created appstudiov1alpha1.Component
+	err = fakeClient.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: components[0].Name}, &created)
+	require.NoError(t, err)
+	assert.Equal(t, "application", created.Spec.Application)
+	assert.Equal(t, "https://github.com/devfile-samples/devfile-sample-frontend", created.Spec.Source.GitSource.URL)
+}
+
+// completedCDQ returns a successfully completed ComponentDetectionQuery in the default namespace, whose detected components
+// are keyed by the keys of stubNames and have the corresponding component stub names.
+func completedCDQ(stubNames map[string]string) appstudiov1alpha1.ComponentDetectionQuery {
+	detected := appstudiov1alpha1.ComponentDetectionMap{}
+	for detectedName, stubName := range stubNames {
+		detected[detectedName] = appstudiov1alpha1.ComponentDetectionDescription{
+			DevfileFound: true,
+			ComponentStub: appstudiov1alpha1.ComponentSpec{
+				ComponentName: stubName,
+				Source: appstudiov1alpha1.ComponentSource{
+					ComponentSourceUnion: appstudiov1alpha1.ComponentSourceUnion{
+						GitSource: &appstudiov1alpha1.GitSource{
+							URL:     "https://github.com/devfile-samples/devfile-sample-" + detectedName,
+							Context: "./",
+						},
+					},
+				},
+			},
+		}
+	}
+
+	return appstudiov1alpha1.ComponentDetectionQuery{
+		ObjectMeta: metav1.ObjectMeta{Name: "cdq", Namespace: "default"},
+		Status: appstudiov1alpha1.ComponentDetectionQueryStatus{
+			Conditions: []metav1.Condition{
+				{
+					Type:    CompletedConditionType,
+					Status:  metav1.ConditionTrue,
+					Reason:  "OK",
+					Message: "ComponentDetectionQuery has successfully finished",
+				},
+			},
+			ComponentDetected: detected,
+		},
+	}
+}
@@ -0,0 +1,102 @@
+//
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdq
+
+import (
+	"fmt"
+	"sort"
+
+	appstudiov1alpha1 "github.com/konflux-ci/application-api/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+// Diff describes the differences between the components detected by two ComponentDetectionQueries
+type Diff struct {
+	// Added are the names of the components only detected by the second ComponentDetectionQuery
+	Added []string
+
+	// Removed are the names of the components only detected by the first ComponentDetectionQuery
+	Removed []string
+
+	// Changed are the components detected by both ComponentDetectionQueries whose devfile URL or target port differ
+	Changed []ComponentChange
+}
+
+// ComponentChange describes how a component detected by two ComponentDetectionQueries differs between them
+type ComponentChange struct {
+	// Name is the name the component was detected under
+	Name string
+
+	OldDevfileURL string
+	NewDevfileURL string
+
+	OldTargetPort int
+	NewTargetPort int
+}
+
+// IsEmpty returns true if both ComponentDetectionQueries detected the same components
+func (d Diff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffCDQs compares the components detected by two completed ComponentDetectionQueries, for example from before and after a
+// repository was restructured. Components are matched by the name they were detected under, and the results are sorted by name.
+func DiffCDQs(before, after *appstudiov1alpha1.ComponentDetectionQuery) (Diff, error) {
+	for _, cdq := range []*appstudiov1alpha1.ComponentDetectionQuery{before, after} {
+		if !meta.IsStatusConditionTrue(cdq.Status.Conditions, CompletedConditionType) {
+			return Diff{}, fmt.Errorf("ComponentDetectionQuery %s has not completed successfully", cdq.Name)
+		}
+	}
+
+	diff := Diff{}
+	for name, oldDetected := range before.Status.ComponentDetected {
+		newDetected, ok := after.Status.ComponentDetected[name]
+		if !ok {
+			diff.Removed = append(diff.Removed, name)
+			continue
+		}
+		change := ComponentChange{
+			Name:          name,
+			OldDevfileURL: devfileURL(oldDetected.ComponentStub),
+			NewDevfileURL: devfileURL(newDetected.ComponentStub),
+			OldTargetPort: oldDetected.ComponentStub.TargetPort,
+			NewTargetPort: newDetected.ComponentStub.TargetPort,
+		}
+		if change.OldDevfileURL != change.NewDevfileURL || change.OldTargetPort != change.NewTargetPort {
+			diff.Changed = append(diff.Changed, change)
+		}
+	}
+	for name := range after.Status.ComponentDetected {
+		if _, ok := before.Status.ComponentDetected[name]; !ok {
+			diff.Added = append(diff.Added, name)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Slice(diff.Changed, func(i, j int) bool {
+		return diff.Changed[i].Name < diff.Changed[j].Name
+	})
+	return diff, nil
+}
+
+// devfileURL returns the devfile URL of a component stub's git source, if any
+func devfileURL(stub appstudiov1alpha1.ComponentSpec) string {
+	if stub.Source.GitSource == nil {
+		return ""
+	}
+	return stub.Source.GitSource.DevfileURL
+}
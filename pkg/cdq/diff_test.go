@@ -0,0 +1,55 @@
+//
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdq
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffCDQs(t *testing.T) {
+	before := completedCDQ(map[string]string{"frontend": "frontend", "backend": "backend", "worker": "worker"})
+	after := completedCDQ(map[string]string{"frontend": "frontend", "backend": "backend", "api": "api"})
+
+	frontend := after.Status.ComponentDetected["frontend"]
+	frontend.ComponentStub.TargetPort = 8080
+	after.Status.ComponentDetected["frontend"] = frontend
+	backend := after.Status.ComponentDetected["backend"]
+	backend.ComponentStub.Source.GitSource.DevfileURL = "https://registry.devfile.io/devfiles/go"
+	after.Status.ComponentDetected["backend"] = backend
+
+	diff, err := DiffCDQs(&before, &after)
+	require.NoError(t, err)
+	assert.Equal(t, Diff{
+		Added:   []string{"api"},
+		Removed: []string{"worker"},
+		Changed: []ComponentChange{
+			{Name: "backend", NewDevfileURL: "https://registry.devfile.io/devfiles/go"},
+			{Name: "frontend", NewTargetPort: 8080},
+		},
+	}, diff)
+	assert.False(t, diff.IsEmpty())
+
+	diff, err = DiffCDQs(&before, &before)
+	require.NoError(t, err)
+	assert.True(t, diff.IsEmpty())
+
+	before.Status.Conditions = nil
+	_, err = DiffCDQs(&before, &after)
+	assert.ErrorContains(t, err, "has not completed successfully")
+}
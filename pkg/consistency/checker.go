@@ -0,0 +1,243 @@
+//
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consistency
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	appstudiov1alpha1 "github.com/konflux-ci/application-api/api/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// FindingType identifies the kind of inconsistency a Finding describes
+type FindingType string
+
+const (
+	// MissingApplication is reported for Components whose Application doesn't exist
+	MissingApplication FindingType = "MissingApplication"
+
+	// DuplicateComponentName is reported for Components sharing their spec.componentName with another Component of the same Application
+	DuplicateComponentName FindingType = "DuplicateComponentName"
+
+	// MissingOwnerReference is reported for Components that aren't owned by their existing Application
+	MissingOwnerReference FindingType = "MissingOwnerReference"
+)
+
+// findingTypes lists every FindingType, so that the findings gauge is reset for types without findings
+var findingTypes = []FindingType{MissingApplication, DuplicateComponentName, MissingOwnerReference}
+
+var findingsGauge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "has_consistency_findings",
+		Help: "Number of inconsistencies found between Applications and Components by the last consistency check",
+	},
+	[]string{"type"},
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(findingsGauge)
+}
+
+// Finding describes an inconsistency found on a Component
+type Finding struct {
+	Type      FindingType
+	Component *appstudiov1alpha1.Component
+	Message   string
+}
+
+// Checker periodically audits Applications and Components for inconsistencies, reporting them as Events on the affected
+// Components and through the has_consistency_findings metric
+type Checker struct {
+	Client   client.Client
+	Recorder record.EventRecorder
+	Log      logr.Logger
+
+	// Interval is the time between two consistency checks
+	Interval time.Duration
+
+	// Repair, if true, sets the missing Application owner references on Components
+	Repair bool
+
+	// ReadOnly, if true, only logs the repairs that would be made
+	ReadOnly bool
+}
+
+// Start runs the consistency check every Interval until the context is cancelled
+func (c *Checker) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, c.run, c.Interval)
+	return nil
+}
+
+// NeedLeaderElection returns true, so that only one replica reports and repairs inconsistencies
+func (c *Checker) NeedLeaderElection() bool {
+	return true
+}
+
+// run performs a consistency check and reports, and optionally repairs, its findings
+func (c *Checker) run(ctx context.Context) {
+	findings, err := c.Check(ctx)
+	if err != nil {
+		c.Log.Error(err, "unable to check the consistency of Applications and Components")
+		return
+	}
+
+	counts := make(map[FindingType]int)
+	for _, finding := range findings {
+		counts[finding.Type]++
+		c.Log.Info("found inconsistency", "type", finding.Type, "name", finding.Component.Name, "namespace", finding.Component.Namespace, "message", finding.Message)
+		c.Recorder.Event(finding.Component, corev1.EventTypeWarning, string(finding.Type), finding.Message)
+
+		if c.Repair && finding.Type == MissingOwnerReference {
+			if c.ReadOnly {
+				c.Log.Info("read-only mode, skipping setting owner reference on component", "name", finding.Component.Name, "namespace", finding.Component.Namespace, "application", finding.Component.Spec.Application)
+				continue
+			}
+			if err := c.repairOwnerReference(ctx, finding.Component); err != nil {
+				c.Log.Error(err, "unable to set the owner reference on component", "name", finding.Component.Name, "namespace", finding.Component.Namespace)
+			}
+		}
+	}
+	for _, findingType := range findingTypes {
+		findingsGauge.WithLabelValues(string(findingType)).Set(float64(counts[findingType]))
+	}
+}
+
+// Check returns the inconsistencies between the Applications and Components visible to the client
+func (c *Checker) Check(ctx context.Context) ([]Finding, error) {
+	var applicationList appstudiov1alpha1.ApplicationList
+	if err := c.Client.List(ctx, &applicationList); err != nil {
+		return nil, err
+	}
+	var componentList appstudiov1alpha1.ComponentList
+	if err := c.Client.List(ctx, &componentList); err != nil {
+		return nil, err
+	}
+
+	applications := make(map[types.NamespacedName]*appstudiov1alpha1.Application)
+	for i := range applicationList.Items {
+		application := &applicationList.Items[i]
+		applications[types.NamespacedName{Namespace: application.Namespace, Name: application.Name}] = application
+	}
+
+	var findings []Finding
+	componentsByName := make(map[string][]*appstudiov1alpha1.Component)
+	for i := range componentList.Items {
+		component := &componentList.Items[i]
+		if !component.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		application, ok := applications[types.NamespacedName{Namespace: component.Namespace, Name: component.Spec.Application}]
+		if !ok {
+			findings = append(findings, Finding{
+				Type:      MissingApplication,
+				Component: component,
+				Message:   fmt.Sprintf("Application %s of Component %s does not exist", component.Spec.Application, component.Name),
+			})
+			continue
+		}
+		if !isOwnedBy(component, application) {
+			findings = append(findings, Finding{
+				Type:      MissingOwnerReference,
+				Component: component,
+				Message:   fmt.Sprintf("Component %s is not owned by its Application %s", component.Name, application.Name),
+			})
+		}
+
+		if component.Spec.ComponentName != "" {
+			key := component.Namespace + "/" + component.Spec.Application + "/" + component.Spec.ComponentName
+			componentsByName[key] = append(componentsByName[key], component)
+		}
+	}
+
+	for _, components := range componentsByName {
+		if len(components) < 2 {
+			continue
+		}
+		var names []string
+		for _, component := range components {
+			names = append(names, component.Name)
+		}
+		sort.Strings(names)
+		for _, component := range components {
+			findings = append(findings, Finding{
+				Type:      DuplicateComponentName,
+				Component: component,
+				Message: fmt.Sprintf("component name %s is used by multiple Components of Application %s: %s",
+					component.Spec.ComponentName, component.Spec.Application, strings.Join(names, ", ")),
+			})
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Component.Namespace != findings[j].Component.Namespace {
+			return findings[i].Component.Namespace < findings[j].Component.Namespace
+		}
+		return findings[i].Component.Name < findings[j].Component.Name
+	})
+	return findings, nil
+}
+
+// repairOwnerReference sets the owner reference of the Component's Application on the Component, if it's still missing
+func (c *Checker) repairOwnerReference(ctx context.Context, component *appstudiov1alpha1.Component) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var application appstudiov1alpha1.Application
+		if err := c.Client.Get(ctx, types.NamespacedName{Namespace: component.Namespace, Name: component.Spec.Application}, &application); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		var curComp appstudiov1alpha1.Component
+		if err := c.Client.Get(ctx, client.ObjectKeyFromObject(component), &curComp); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if isOwnedBy(&curComp, &application) {
+			return nil
+		}
+
+		curComp.SetOwnerReferences(append(curComp.GetOwnerReferences(), metav1.OwnerReference{
+			APIVersion: appstudiov1alpha1.GroupVersion.String(),
+			Kind:       "Application",
+			Name:       application.Name,
+			UID:        application.UID,
+		}))
+		c.Log.Info("setting owner reference on component", "name", curComp.Name, "namespace", curComp.Namespace, "application", application.Name)
+		return c.Client.Update(ctx, &curComp)
+	})
+}
+
+// isOwnedBy returns true if the Component has an owner reference to the Application
+func isOwnedBy(component *appstudiov1alpha1.Component, application *appstudiov1alpha1.Application) bool {
+	for _, ownerReference := range component.OwnerReferences {
+		if ownerReference.UID == application.UID {
+			return true
+		}
+	}
+	return false
+}
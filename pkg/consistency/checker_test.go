@@ -0,0 +1,123 @@
+//
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consistency
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	appstudiov1alpha1 "github.com/konflux-ci/application-api/api/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCheck(t *testing.T) {
+	checker := &Checker{Client: setUpClient(t), Log: logr.Discard()}
+
+	findings, err := checker.Check(context.Background())
+	require.NoError(t, err)
+
+	type result struct {
+		findingType FindingType
+		component   string
+	}
+	var results []result
+	for _, finding := range findings {
+		results = append(results, result{finding.Type, finding.Component.Name})
+	}
+	assert.Equal(t, []result{
+		{DuplicateComponentName, "backend"},
+		{DuplicateComponentName, "backend-copy"},
+		{MissingOwnerReference, "frontend"},
+		{MissingApplication, "orphan"},
+	}, results)
+	assert.Equal(t, "component name backend is used by multiple Components of Application app: backend, backend-copy", findings[0].Message)
+}
+
+func TestRun(t *testing.T) {
+	fakeClient := setUpClient(t)
+	recorder := record.NewFakeRecorder(10)
+	checker := &Checker{Client: fakeClient, Recorder: recorder, Log: logr.Discard(), Repair: true}
+
+	checker.run(context.Background())
+
+	assert.Len(t, recorder.Events, 4)
+	assert.Equal(t, float64(2), testutil.ToFloat64(findingsGauge.WithLabelValues(string(DuplicateComponentName))))
+	assert.Equal(t, float64(1), testutil.ToFloat64(findingsGauge.WithLabelValues(string(MissingOwnerReference))))
+	assert.Equal(t, float64(1), testutil.ToFloat64(findingsGauge.WithLabelValues(string(MissingApplication))))
+
+	// The missing owner reference must have been repaired
+	var frontend appstudiov1alpha1.Component
+	err := fakeClient.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "frontend"}, &frontend)
+	require.NoError(t, err)
+	require.Len(t, frontend.OwnerReferences, 1)
+	assert.Equal(t, "Application", frontend.OwnerReferences[0].Kind)
+	assert.Equal(t, "app", frontend.OwnerReferences[0].Name)
+
+	findings, err := checker.Check(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, findings, 3)
+}
+
+func TestRunReadOnly(t *testing.T) {
+	fakeClient := setUpClient(t)
+	recorder := record.NewFakeRecorder(10)
+	checker := &Checker{Client: fakeClient, Recorder: recorder, Log: logr.Discard(), Repair: true, ReadOnly: true}
+
+	checker.run(context.Background())
+
+	// Findings are still reported, but the owner reference must not be set
+	assert.Len(t, recorder.Events, 4)
+	var frontend appstudiov1alpha1.Component
+	err := fakeClient.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "frontend"}, &frontend)
+	require.NoError(t, err)
+	assert.Empty(t, frontend.OwnerReferences)
+}
+
+// setUpClient creates a fake client with an Application and Components exhibiting every kind of inconsistency
+func setUpClient(t *testing.T) client.Client {
+	s := scheme.Scheme
+	err := appstudiov1alpha1.AddToScheme(s)
+	require.NoError(t, err)
+
+	application := &appstudiov1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", UID: "app-uid"},
+	}
+	ownerReferences := []metav1.OwnerReference{
+		{APIVersion: appstudiov1alpha1.GroupVersion.String(), Kind: "Application", Name: "app", UID: "app-uid"},
+	}
+	component := func(name, componentName, application string, ownerReferences []metav1.OwnerReference) *appstudiov1alpha1.Component {
+		return &appstudiov1alpha1.Component{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", OwnerReferences: ownerReferences},
+			Spec:       appstudiov1alpha1.ComponentSpec{ComponentName: componentName, Application: application},
+		}
+	}
+
+	return fake.NewClientBuilder().WithScheme(s).WithObjects(
+		application,
+		component("backend", "backend", "app", ownerReferences),
+		component("backend-copy", "backend", "app", ownerReferences),
+		component("frontend", "frontend", "app", nil),
+		component("orphan", "orphan", "missing-app", nil),
+	).Build()
+}
@@ -0,0 +1,143 @@
+//
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuregates
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redhat-appstudio/application-service/pkg/util"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// EnvVar is the environment variable containing the feature gates to set, e.g. Foo=true,Bar=false
+const EnvVar = "HAS_FEATURE_GATES"
+
+// Feature is the name of a feature gate
+type Feature string
+
+const (
+	// ComponentOwnershipLabels enables stamping the application and ownership labels onto Components
+	ComponentOwnershipLabels Feature = "ComponentOwnershipLabels"
+)
+
+// defaultFeatures lists every known feature gate along with whether it is enabled by default
+var defaultFeatures = map[Feature]bool{
+	ComponentOwnershipLabels: true,
+}
+
+var featureGateEnabled = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "has_feature_gate_enabled",
+		Help: "Whether a feature gate is enabled (1) or disabled (0)",
+	},
+	[]string{"name"},
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(featureGateEnabled)
+}
+
+// Default holds the feature gates of application-service
+var Default = New(defaultFeatures)
+
+// FeatureGates is a registry of known feature gates and their states
+type FeatureGates struct {
+	lock    sync.RWMutex
+	enabled map[Feature]bool
+}
+
+// New returns feature gates knowing the given features, set to their default state
+func New(defaults map[Feature]bool) *FeatureGates {
+	enabled := make(map[Feature]bool, len(defaults))
+	for feature, state := range defaults {
+		enabled[feature] = state
+	}
+	return &FeatureGates{enabled: enabled}
+}
+
+// Set parses a comma separated list of Feature=bool pairs and sets the listed feature gates. Nothing is set if any of the
+// feature gates is unknown or has an invalid value.
+func (f *FeatureGates) Set(value string) error {
+	states := make(map[Feature]bool)
+	for _, pair := range util.SplitCommaSeparated(value) {
+		name, stateStr, found := strings.Cut(pair, "=")
+		if !found {
+			return fmt.Errorf("invalid feature gate %q: must be of the form Feature=true|false", pair)
+		}
+		feature := Feature(strings.TrimSpace(name))
+		state, err := strconv.ParseBool(strings.TrimSpace(stateStr))
+		if err != nil {
+			return fmt.Errorf("invalid value %q for feature gate %s: %v", stateStr, feature, err)
+		}
+		states[feature] = state
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for feature := range states {
+		if _, ok := f.enabled[feature]; !ok {
+			return fmt.Errorf("unknown feature gate %s, known feature gates are: %s", feature, strings.Join(f.known(), ", "))
+		}
+	}
+	for feature, state := range states {
+		f.enabled[feature] = state
+	}
+	return nil
+}
+
+// Enabled returns true if the feature gate is enabled. Unknown feature gates are disabled.
+func (f *FeatureGates) Enabled(feature Feature) bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.enabled[feature]
+}
+
+// States returns the state of every known feature gate
+func (f *FeatureGates) States() map[Feature]bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	states := make(map[Feature]bool, len(f.enabled))
+	for feature, state := range f.enabled {
+		states[feature] = state
+	}
+	return states
+}
+
+// RecordMetrics exports the state of every known feature gate through the has_feature_gate_enabled metric
+func (f *FeatureGates) RecordMetrics() {
+	for feature, state := range f.States() {
+		value := 0.0
+		if state {
+			value = 1
+		}
+		featureGateEnabled.WithLabelValues(string(feature)).Set(value)
+	}
+}
+
+// known returns the sorted names of the known feature gates. The caller must hold the lock.
+func (f *FeatureGates) known() []string {
+	names := make([]string, 0, len(f.enabled))
+	for feature := range f.enabled {
+		names = append(names, string(feature))
+	}
+	sort.Strings(names)
+	return names
+}
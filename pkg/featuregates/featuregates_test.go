@@ -0,0 +1,87 @@
+//
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuregates
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSet(t *testing.T) {
+	defaults := map[Feature]bool{"Foo": false, "Bar": true}
+
+	tests := []struct {
+		name  string
+		value string
+		want  map[Feature]bool
+		err   string
+	}{
+		{
+			name: "nothing set",
+			want: map[Feature]bool{"Foo": false, "Bar": true},
+		},
+		{
+			name:  "feature gates set",
+			value: "Foo=true, Bar = false",
+			want:  map[Feature]bool{"Foo": true, "Bar": false},
+		},
+		{
+			name:  "unknown feature gate",
+			value: "Foo=true,Baz=true",
+			want:  map[Feature]bool{"Foo": false, "Bar": true},
+			err:   "unknown feature gate Baz, known feature gates are: Bar, Foo",
+		},
+		{
+			name:  "invalid value",
+			value: "Foo=yes",
+			want:  map[Feature]bool{"Foo": false, "Bar": true},
+			err:   "invalid value \"yes\" for feature gate Foo",
+		},
+		{
+			name:  "missing value",
+			value: "Foo",
+			want:  map[Feature]bool{"Foo": false, "Bar": true},
+			err:   "invalid feature gate \"Foo\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gates := New(defaults)
+			err := gates.Set(tt.value)
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.err)
+			}
+			assert.Equal(t, tt.want, gates.States())
+			for feature, state := range tt.want {
+				assert.Equal(t, state, gates.Enabled(feature))
+			}
+			assert.False(t, gates.Enabled("Unknown"))
+		})
+	}
+}
+
+func TestRecordMetrics(t *testing.T) {
+	gates := New(map[Feature]bool{"MetricsFoo": true, "MetricsBar": false})
+	gates.RecordMetrics()
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(featureGateEnabled.WithLabelValues("MetricsFoo")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(featureGateEnabled.WithLabelValues("MetricsBar")))
+}
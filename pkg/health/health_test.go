@@ -0,0 +1,57 @@
+//
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package health
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+)
+
+func TestCacheSyncChecker(t *testing.T) {
+	synced := true
+	notSynced := false
+
+	tests := []struct {
+		name   string
+		synced *bool
+		err    string
+	}{
+		{
+			name:   "caches have synced",
+			synced: &synced,
+		},
+		{
+			name:   "caches have not synced",
+			synced: &notSynced,
+			err:    "informer caches have not synced yet",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := CacheSyncChecker(&informertest.FakeInformers{Synced: tt.synced})
+			err := checker(httptest.NewRequest("GET", "/readyz", nil))
+			if tt.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
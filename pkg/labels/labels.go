@@ -0,0 +1,182 @@
+//
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package labels
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/go-logr/logr"
+	appstudiov1alpha1 "github.com/konflux-ci/application-api/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ApplicationLabel is set on Components to the name of the Application they belong to
+	ApplicationLabel = "appstudio.redhat.com/application"
+
+	// PartOfLabel is the recommended Kubernetes label for the higher level application a resource is part of
+	PartOfLabel = "app.kubernetes.io/part-of"
+
+	// TeamLabel is copied from the annotation of the same name on the Application onto its Components
+	TeamLabel = "appstudio.redhat.com/team"
+
+	// OwnerLabel is copied from the annotation of the same name on the Application onto its Components
+	OwnerLabel = "appstudio.redhat.com/owner"
+)
+
+// Apply stamps the standardized application and ownership labels onto the Component and returns true if its labels
+// changed. The part-of label is only set if the Component doesn't already have one. The team and owner labels are copied
+// from the annotations of the Component's Application, or removed if it doesn't have them; they are left untouched if the
+// Application is nil because it couldn't be retrieved. Changes to the Application's annotations are not propagated: the
+// labels are only refreshed when the Component is admitted, and by the Migrator at startup.
+func Apply(component *appstudiov1alpha1.Component, application *appstudiov1alpha1.Application) bool {
+	original := component.GetLabels()
+	componentLabels := make(map[string]string, len(original)+4)
+	for key, value := range original {
+		componentLabels[key] = value
+	}
+
+	if applicationName := component.Spec.Application; applicationName != "" && len(validation.IsValidLabelValue(applicationName)) == 0 {
+		componentLabels[ApplicationLabel] = applicationName
+		if _, ok := componentLabels[PartOfLabel]; !ok {
+			componentLabels[PartOfLabel] = applicationName
+		}
+	}
+
+	if application != nil {
+		for _, key := range []string{TeamLabel, OwnerLabel} {
+			if value, ok := application.Annotations[key]; ok && len(validation.IsValidLabelValue(value)) == 0 {
+				componentLabels[key] = value
+			} else {
+				delete(componentLabels, key)
+			}
+		}
+	}
+
+	if equality.Semantic.DeepEqual(componentLabels, original) {
+		return false
+	}
+	component.SetLabels(componentLabels)
+	return true
+}
+
+// defaultBackoff is the backoff between two attempts at labelling the Components that couldn't be labelled, for example
+// because the application-service webhook, that admits the patches, isn't ready yet
+var defaultBackoff = wait.Backoff{
+	Duration: time.Second,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    math.MaxInt32,
+	Cap:      5 * time.Minute,
+}
+
+// Migrator stamps the labels set by Apply onto the existing Components at startup, covering those created before the labels
+// were introduced and those whose Application annotations changed since they were last admitted
+type Migrator struct {
+	Client client.Client
+	Log    logr.Logger
+
+	// ReadOnly, if true, only logs the Components that would be labelled
+	ReadOnly bool
+
+	// Backoff is the backoff between two attempts at labelling the Components that couldn't be labelled. Defaults to
+	// exponential backoff from one second up to five minutes.
+	Backoff wait.Backoff
+}
+
+// Start labels every existing Component, retrying with backoff until every Component is labelled or the context is
+// cancelled. Errors are logged rather than returned, so that they don't stop the manager.
+func (m *Migrator) Start(ctx context.Context) error {
+	backoff := m.Backoff
+	if backoff.Duration == 0 {
+		backoff = defaultBackoff
+	}
+
+	for {
+		failed, err := m.labelComponents(ctx)
+		if err == nil && failed == 0 {
+			return nil
+		}
+
+		delay := backoff.Step()
+		if err != nil {
+			m.Log.Error(err, "unable to label components, retrying", "after", delay)
+		} else {
+			m.Log.Info("some components could not be labelled, retrying", "failed", failed, "after", delay)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+// labelComponents labels every existing Component whose labels are out of date, and returns the number of Components that
+// couldn't be labelled
+func (m *Migrator) labelComponents(ctx context.Context) (int, error) {
+	var applicationList appstudiov1alpha1.ApplicationList
+	if err := m.Client.List(ctx, &applicationList); err != nil {
+		return 0, err
+	}
+	var componentList appstudiov1alpha1.ComponentList
+	if err := m.Client.List(ctx, &componentList); err != nil {
+		return 0, err
+	}
+
+	applications := make(map[types.NamespacedName]*appstudiov1alpha1.Application)
+	for i := range applicationList.Items {
+		application := &applicationList.Items[i]
+		applications[types.NamespacedName{Namespace: application.Namespace, Name: application.Name}] = application
+	}
+
+	labelled, failed := 0, 0
+	for i := range componentList.Items {
+		component := &componentList.Items[i]
+		if !component.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		patch := client.MergeFrom(component.DeepCopy())
+		application := applications[types.NamespacedName{Namespace: component.Namespace, Name: component.Spec.Application}]
+		if !Apply(component, application) {
+			continue
+		}
+		if m.ReadOnly {
+			m.Log.Info("read-only mode, skipping labelling component", "name", component.Name, "namespace", component.Namespace, "labels", component.Labels)
+			continue
+		}
+		if err := m.Client.Patch(ctx, component, patch); err != nil {
+			m.Log.Error(err, "unable to label component", "name", component.Name, "namespace", component.Namespace)
+			failed++
+			continue
+		}
+		labelled++
+	}
+	m.Log.Info("labelled existing components", "count", labelled)
+	return failed, nil
+}
+
+// NeedLeaderElection returns true, so that only one replica labels the existing Components
+func (m *Migrator) NeedLeaderElection() bool {
+	return true
+}
@@ -0,0 +1,175 @@
+//
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package labels
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	appstudiov1alpha1 "github.com/konflux-ci/application-api/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestApply(t *testing.T) {
+	application := &appstudiov1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "app",
+			Annotations: map[string]string{TeamLabel: "team-a", OwnerLabel: "invalid owner value"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		application *appstudiov1alpha1.Application
+		want        map[string]string
+		wantChanged bool
+	}{
+		{
+			name:        "labels are set from the application",
+			labels:      map[string]string{"custom": "label"},
+			application: application,
+			want:        map[string]string{"custom": "label", ApplicationLabel: "app", PartOfLabel: "app", TeamLabel: "team-a"},
+			wantChanged: true,
+		},
+		{
+			name:        "existing part-of label is kept",
+			labels:      map[string]string{PartOfLabel: "product"},
+			application: application,
+			want:        map[string]string{ApplicationLabel: "app", PartOfLabel: "product", TeamLabel: "team-a"},
+			wantChanged: true,
+		},
+		{
+			name:        "labels are removed with the application annotations",
+			labels:      map[string]string{ApplicationLabel: "app", PartOfLabel: "app", TeamLabel: "team-a", OwnerLabel: "someone"},
+			application: &appstudiov1alpha1.Application{ObjectMeta: metav1.ObjectMeta{Name: "app"}},
+			want:        map[string]string{ApplicationLabel: "app", PartOfLabel: "app"},
+			wantChanged: true,
+		},
+		{
+			name:        "ownership labels are kept if the application is not found",
+			labels:      map[string]string{TeamLabel: "team-a", OwnerLabel: "someone"},
+			want:        map[string]string{ApplicationLabel: "app", PartOfLabel: "app", TeamLabel: "team-a", OwnerLabel: "someone"},
+			wantChanged: true,
+		},
+		{
+			name:        "nothing changes if the labels are up to date",
+			labels:      map[string]string{ApplicationLabel: "app", PartOfLabel: "app", TeamLabel: "team-a"},
+			application: application,
+			want:        map[string]string{ApplicationLabel: "app", PartOfLabel: "app", TeamLabel: "team-a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			component := &appstudiov1alpha1.Component{
+				ObjectMeta: metav1.ObjectMeta{Name: "component", Labels: tt.labels},
+				Spec:       appstudiov1alpha1.ComponentSpec{Application: "app"},
+			}
+			assert.Equal(t, tt.wantChanged, Apply(component, tt.application))
+			assert.Equal(t, tt.want, component.Labels)
+		})
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	for _, readOnly := range []bool{false, true} {
+		fakeClient := setUpClient(t)
+		migrator := &Migrator{Client: fakeClient, Log: logr.Discard(), ReadOnly: readOnly}
+		require.NoError(t, migrator.Start(context.Background()))
+
+		var unlabelled, stale appstudiov1alpha1.Component
+		require.NoError(t, fakeClient.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "unlabelled"}, &unlabelled))
+		require.NoError(t, fakeClient.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "stale"}, &stale))
+		if readOnly {
+			assert.Empty(t, unlabelled.Labels)
+			assert.Equal(t, "old-team", stale.Labels[TeamLabel])
+		} else {
+			assert.Equal(t, map[string]string{ApplicationLabel: "app", PartOfLabel: "app", TeamLabel: "team-a"}, unlabelled.Labels)
+			assert.Equal(t, map[string]string{ApplicationLabel: "app", PartOfLabel: "product", TeamLabel: "team-a"}, stale.Labels)
+		}
+	}
+}
+
+func TestMigratorRetry(t *testing.T) {
+	fakeClient := &failingPatchClient{Client: setUpClient(t), failures: 2}
+	migrator := &Migrator{Client: fakeClient, Log: logr.Discard(), Backoff: wait.Backoff{Duration: time.Millisecond, Factor: 2, Steps: 10}}
+	require.NoError(t, migrator.Start(context.Background()))
+
+	// Both components must be labelled, after the first two patches failed
+	assert.Equal(t, 4, fakeClient.patches)
+	for _, name := range []string{"unlabelled", "stale"} {
+		var component appstudiov1alpha1.Component
+		require.NoError(t, fakeClient.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: name}, &component))
+		assert.Equal(t, "team-a", component.Labels[TeamLabel])
+	}
+}
+
+func TestMigratorCancelled(t *testing.T) {
+	fakeClient := &failingPatchClient{Client: setUpClient(t), failures: -1}
+	migrator := &Migrator{Client: fakeClient, Log: logr.Discard(), Backoff: wait.Backoff{Duration: time.Hour}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.NoError(t, migrator.Start(ctx))
+	assert.Equal(t, 2, fakeClient.patches)
+}
+
+// failingPatchClient fails the first failures patches, or every patch if failures is negative
+type failingPatchClient struct {
+	client.Client
+	failures int
+	patches  int
+}
+
+func (c *failingPatchClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.patches++
+	if c.failures < 0 || c.patches <= c.failures {
+		return errors.New("webhook not ready")
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+// setUpClient creates a fake client with an annotated Application, an unlabelled Component and a Component with stale labels
+func setUpClient(t *testing.T) client.Client {
+	s := scheme.Scheme
+	err := appstudiov1alpha1.AddToScheme(s)
+	require.NoError(t, err)
+
+	return fake.NewClientBuilder().WithScheme(s).WithObjects(
+		&appstudiov1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", Annotations: map[string]string{TeamLabel: "team-a"}},
+		},
+		&appstudiov1alpha1.Component{
+			ObjectMeta: metav1.ObjectMeta{Name: "unlabelled", Namespace: "default"},
+			Spec:       appstudiov1alpha1.ComponentSpec{ComponentName: "unlabelled", Application: "app"},
+		},
+		&appstudiov1alpha1.Component{
+			ObjectMeta: metav1.ObjectMeta{Name: "stale", Namespace: "default", Labels: map[string]string{
+				PartOfLabel: "product", TeamLabel: "old-team", OwnerLabel: "someone",
+			}},
+			Spec: appstudiov1alpha1.ComponentSpec{ComponentName: "stale", Application: "app"},
+		},
+	).Build()
+}
@@ -0,0 +1,293 @@
+//
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/cache"
+	authenticationv1client "k8s.io/client-go/kubernetes/typed/authentication/v1"
+	authorizationv1client "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	certutil "k8s.io/client-go/util/cert"
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// metricsEndpoint is the path the metrics are served on
+const metricsEndpoint = "/metrics"
+
+// SecureServer serves the controller-runtime metrics registry over TLS, optionally authenticating and authorizing every
+// request against the Kubernetes API in the same way kube-rbac-proxy does. It implements manager.Runnable.
+type SecureServer struct {
+	// BindAddress is the TCP address the server listens on
+	BindAddress string
+
+	// CertDir is the directory containing the serving certificate and key. The files are watched and reloaded when
+	// they change. If empty, a self-signed certificate is generated at startup.
+	CertDir string
+
+	// CertName is the name of the serving certificate file in CertDir
+	CertName string
+
+	// KeyName is the name of the serving key file in CertDir
+	KeyName string
+
+	// Auth, if set, is used to authenticate and authorize every request to the server
+	Auth *DelegatingAuth
+
+	Log logr.Logger
+}
+
+// DelegatingAuth authenticates bearer tokens with TokenReviews and authorizes the authenticated user with
+// SubjectAccessReviews against the non-resource URL of the request. Like kube-rbac-proxy, the results of both reviews
+// are cached for a short time, so that every scrape doesn't hit the API server.
+type DelegatingAuth struct {
+	TokenReviews         authenticationv1client.TokenReviewInterface
+	SubjectAccessReviews authorizationv1client.SubjectAccessReviewInterface
+
+	// tokenReviews caches the status of TokenReviews, keyed by the hash of the token
+	tokenReviews *cache.LRUExpireCache
+
+	// accessReviews caches whether SubjectAccessReviews were allowed, keyed by user, path and verb
+	accessReviews *cache.LRUExpireCache
+}
+
+const (
+	// authCacheSize is the maximum number of entries of each of the review caches
+	authCacheSize = 1024
+
+	// The review cache TTLs, matching the defaults of kube-rbac-proxy
+	authenticatedTTL   = 2 * time.Minute
+	unauthenticatedTTL = 10 * time.Second
+	allowedTTL         = 5 * time.Minute
+	deniedTTL          = 30 * time.Second
+)
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable so that metrics are served by every replica
+func (s *SecureServer) NeedLeaderElection() bool {
+	return false
+}
+
+// Start implements manager.Runnable. It serves metrics until ctx is cancelled.
+func (s *SecureServer) Start(ctx context.Context) error {
+	listener, err := s.listen(ctx)
+	if err != nil {
+		return err
+	}
+	return s.serve(ctx, listener)
+}
+
+// listen returns a TLS listener on the server's bind address
+func (s *SecureServer) listen(ctx context.Context) (net.Listener, error) {
+	tlsConfig, err := s.tlsConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := tls.Listen("tcp", s.BindAddress, tlsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen on %s: %v", s.BindAddress, err)
+	}
+	return listener, nil
+}
+
+// serve serves metrics on listener until ctx is cancelled
+func (s *SecureServer) serve(ctx context.Context, listener net.Listener) error {
+	var handler http.Handler = promhttp.HandlerFor(ctrlmetrics.Registry, promhttp.HandlerOpts{
+		ErrorHandling: promhttp.HTTPErrorOnError,
+	})
+	if s.Auth != nil {
+		handler = s.Auth.Wrap(handler, s.Log)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(metricsEndpoint, handler)
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 32 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			s.Log.Error(err, "error shutting down the metrics server")
+		}
+	}()
+
+	s.Log.Info("serving metrics over https", "addr", listener.Addr(), "authenticated", s.Auth != nil)
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// tlsConfig returns the TLS configuration of the server, starting a certificate watcher if a certificate directory is configured
+func (s *SecureServer) tlsConfig(ctx context.Context) (*tls.Config, error) {
+	// http/2 is disabled due to CVE-2023-44487, in the same way as on the webhook server
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"http/1.1"},
+	}
+
+	if s.CertDir == "" {
+		host, _, err := net.SplitHostPort(s.BindAddress)
+		if err != nil || host == "" {
+			host = "localhost"
+		}
+		certPEM, keyPEM, err := certutil.GenerateSelfSignedCertKey(host, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("unable to generate a self-signed metrics certificate: %v", err)
+		}
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		return tlsConfig, nil
+	}
+
+	watcher, err := certwatcher.New(filepath.Join(s.CertDir, s.CertName), filepath.Join(s.CertDir, s.KeyName))
+	if err != nil {
+		return nil, fmt.Errorf("unable to load the metrics certificate: %v", err)
+	}
+	go func() {
+		if err := watcher.Start(ctx); err != nil {
+			s.Log.Error(err, "metrics certificate watcher stopped")
+		}
+	}()
+	tlsConfig.GetCertificate = watcher.GetCertificate
+	return tlsConfig, nil
+}
+
+// Wrap returns a handler that only calls next for requests carrying a bearer token of a user allowed to perform the
+// request's verb on its path
+func (a *DelegatingAuth) Wrap(next http.Handler, log logr.Logger) http.Handler {
+	if a.tokenReviews == nil {
+		a.tokenReviews = cache.NewLRUExpireCache(authCacheSize)
+	}
+	if a.accessReviews == nil {
+		a.accessReviews = cache.NewLRUExpireCache(authCacheSize)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get("Authorization")
+		if !strings.HasPrefix(authHeader, "Bearer ") {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		token := strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
+		if token == "" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		tokenStatus, err := a.reviewToken(r.Context(), token)
+		if err != nil {
+			log.Error(err, "unable to review the token of a metrics request")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if !tokenStatus.Authenticated {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		allowed, err := a.reviewAccess(r.Context(), tokenStatus.User, r.URL.Path, strings.ToLower(r.Method))
+		if err != nil {
+			log.Error(err, "unable to review the access of a metrics request")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// reviewToken returns the status of a TokenReview of token, from the cache if it was reviewed recently
+func (a *DelegatingAuth) reviewToken(ctx context.Context, token string) (authenticationv1.TokenReviewStatus, error) {
+	tokenHash := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(tokenHash[:])
+	if status, ok := a.tokenReviews.Get(key); ok {
+		return status.(authenticationv1.TokenReviewStatus), nil
+	}
+
+	tokenReview, err := a.TokenReviews.Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return authenticationv1.TokenReviewStatus{}, err
+	}
+	ttl := unauthenticatedTTL
+	if tokenReview.Status.Authenticated {
+		ttl = authenticatedTTL
+	}
+	a.tokenReviews.Add(key, tokenReview.Status, ttl)
+	return tokenReview.Status, nil
+}
+
+// reviewAccess returns whether user may perform verb on the non-resource path, from the cache if it was reviewed recently
+func (a *DelegatingAuth) reviewAccess(ctx context.Context, user authenticationv1.UserInfo, path, verb string) (bool, error) {
+	key := strings.Join([]string{user.Username, user.UID, strings.Join(user.Groups, ","), path, verb}, "\x00")
+	if allowed, ok := a.accessReviews.Get(key); ok {
+		return allowed.(bool), nil
+	}
+
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for key, value := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+	accessReview, err := a.SubjectAccessReviews.Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Username,
+			UID:    user.UID,
+			Groups: user.Groups,
+			Extra:  extra,
+			NonResourceAttributes: &authorizationv1.NonResourceAttributes{
+				Path: path,
+				Verb: verb,
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+	ttl := deniedTTL
+	if accessReview.Status.Allowed {
+		ttl = allowedTTL
+	}
+	a.accessReviews.Add(key, accessReview.Status.Allowed, ttl)
+	return accessReview.Status.Allowed, nil
+}
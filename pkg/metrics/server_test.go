@@ -0,0 +1,237 @@
+//
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	certutil "k8s.io/client-go/util/cert"
+)
+
+func TestDelegatingAuthWrap(t *testing.T) {
+	tests := []struct {
+		name       string
+		authHeader string
+		reviewErr  error
+		wantStatus int
+	}{
+		{
+			name:       "no authorization header",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "authorization header is not a bearer token",
+			authHeader: "Basic dXNlcjpwYXNz",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "token is not authenticated",
+			authHeader: "Bearer invalid-token",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "user is not authorized",
+			authHeader: "Bearer unauthorized-token",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "user is authorized",
+			authHeader: "Bearer metrics-reader-token",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "token review fails",
+			authHeader: "Bearer metrics-reader-token",
+			reviewErr:  errors.New("some error"),
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset()
+			clientset.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if tt.reviewErr != nil {
+					return true, nil, tt.reviewErr
+				}
+				review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+				switch review.Spec.Token {
+				case "metrics-reader-token":
+					review.Status = authenticationv1.TokenReviewStatus{Authenticated: true, User: authenticationv1.UserInfo{Username: "metrics-reader"}}
+				case "unauthorized-token":
+					review.Status = authenticationv1.TokenReviewStatus{Authenticated: true, User: authenticationv1.UserInfo{Username: "someone"}}
+				}
+				return true, review, nil
+			})
+			clientset.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+				review.Status.Allowed = review.Spec.User == "metrics-reader" &&
+					review.Spec.NonResourceAttributes.Path == "/metrics" &&
+					review.Spec.NonResourceAttributes.Verb == "get"
+				return true, review, nil
+			})
+
+			auth := &DelegatingAuth{
+				TokenReviews:         clientset.AuthenticationV1().TokenReviews(),
+				SubjectAccessReviews: clientset.AuthorizationV1().SubjectAccessReviews(),
+			}
+			handler := auth.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}), logr.Discard())
+
+			request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.authHeader != "" {
+				request.Header.Set("Authorization", tt.authHeader)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			assert.Equal(t, tt.wantStatus, recorder.Code)
+		})
+	}
+}
+
+func TestDelegatingAuthCache(t *testing.T) {
+	tokenReviews, accessReviews := 0, 0
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		tokenReviews++
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		review.Status = authenticationv1.TokenReviewStatus{
+			Authenticated: review.Spec.Token != "invalid-token",
+			User:          authenticationv1.UserInfo{Username: "metrics-reader"},
+		}
+		return true, review, nil
+	})
+	clientset.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		accessReviews++
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		review.Status.Allowed = review.Spec.NonResourceAttributes.Path == "/metrics"
+		return true, review, nil
+	})
+
+	auth := &DelegatingAuth{
+		TokenReviews:         clientset.AuthenticationV1().TokenReviews(),
+		SubjectAccessReviews: clientset.AuthorizationV1().SubjectAccessReviews(),
+	}
+	handler := auth.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), logr.Discard())
+
+	request := func(path, token string) int {
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	// Repeated requests are answered from the cache
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, request("/metrics", "metrics-reader-token"))
+		assert.Equal(t, http.StatusUnauthorized, request("/metrics", "invalid-token"))
+	}
+	assert.Equal(t, 2, tokenReviews)
+	assert.Equal(t, 1, accessReviews)
+
+	// The access review is cached per path
+	assert.Equal(t, http.StatusForbidden, request("/other", "metrics-reader-token"))
+	assert.Equal(t, http.StatusForbidden, request("/other", "metrics-reader-token"))
+	assert.Equal(t, 2, tokenReviews)
+	assert.Equal(t, 2, accessReviews)
+}
+
+func TestSecureServer(t *testing.T) {
+	certPEM, keyPEM, err := certutil.GenerateSelfSignedCertKey("127.0.0.1", nil, nil)
+	require.NoError(t, err)
+	certDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(certDir, "tls.crt"), certPEM, 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(certDir, "tls.key"), keyPEM, 0600))
+
+	tests := []struct {
+		name    string
+		certDir string
+	}{
+		{
+			name: "self-signed certificate",
+		},
+		{
+			name:    "certificate from the certificate directory",
+			certDir: certDir,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			server := &SecureServer{
+				BindAddress: "127.0.0.1:0",
+				CertDir:     tt.certDir,
+				CertName:    "tls.crt",
+				KeyName:     "tls.key",
+				Log:         logr.Discard(),
+			}
+			listener, err := server.listen(ctx)
+			require.NoError(t, err)
+			serveErr := make(chan error, 1)
+			go func() {
+				serveErr <- server.serve(ctx, listener)
+			}()
+
+			tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+			if tt.certDir != "" {
+				// The served certificate must be the one from the certificate directory
+				roots := x509.NewCertPool()
+				require.True(t, roots.AppendCertsFromPEM(certPEM))
+				tlsConfig.RootCAs = roots
+			} else {
+				tlsConfig.InsecureSkipVerify = true // #nosec G402 -- the certificate is generated at startup
+			}
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+
+			response, err := client.Get("https://" + listener.Addr().String() + "/metrics")
+			require.NoError(t, err)
+			body, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+			require.NoError(t, response.Body.Close())
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+			assert.Equal(t, 1, response.ProtoMajor)
+			assert.NotEmpty(t, body)
+
+			cancel()
+			assert.NoError(t, <-serveErr)
+		})
+	}
+}
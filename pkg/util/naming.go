@@ -0,0 +1,105 @@
+//
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// maxNameLength is the maximum length of a DNS-1035 label
+	maxNameLength = 63
+
+	// nameSuffixLength is the length of the random suffix appended to names that are already taken
+	nameSuffixLength = 5
+
+	// maxNameGenerationAttempts is the number of random suffixes tried before giving up on generating a unique name
+	maxNameGenerationAttempts = 10
+)
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// NameExistsFunc reports whether the given name is already taken
+type NameExistsFunc func(name string) (bool, error)
+
+// SanitizeName converts name into a valid DNS-1035 label. Names that are empty or start with a digit once sanitized are given
+// prefix, which must itself be a valid DNS-1035 label, such as "app" or "comp" depending on the kind of resource named.
+func SanitizeName(name, prefix string) string {
+	name = invalidNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	name = strings.Trim(name, "-")
+	if name == "" {
+		name = prefix
+	} else if name[0] >= '0' && name[0] <= '9' {
+		name = prefix + "-" + name
+	}
+	if len(name) > maxNameLength {
+		name = strings.TrimRight(name[:maxNameLength], "-")
+	}
+	return name
+}
+
+// GenerateUniqueName returns the sanitized form of base, see SanitizeName, if it isn't taken according to exists. Otherwise, a random suffix is
+// appended to it, trimming base so that the name stays a valid DNS-1035 label.
+func GenerateUniqueName(base, prefix string, exists NameExistsFunc) (string, error) {
+	name := SanitizeName(base, prefix)
+	taken, err := exists(name)
+	if err != nil {
+		return "", err
+	}
+	for attempt := 0; taken; attempt++ {
+		if attempt == maxNameGenerationAttempts {
+			return "", fmt.Errorf("unable to generate a unique name for %q after %d attempts", base, maxNameGenerationAttempts)
+		}
+		name = withRandomSuffix(SanitizeName(base, prefix))
+		if taken, err = exists(name); err != nil {
+			return "", err
+		}
+	}
+	return name, nil
+}
+
+// CreateWithUniqueName creates obj, named after the sanitized form of its current name, see SanitizeName. If an object with that name already
+// exists, including one created concurrently by another client, a random suffix is appended and the create is retried.
+// setName is called with every name tried and must set it on obj, along with any spec fields that mirror the name.
+func CreateWithUniqueName(ctx context.Context, c client.Client, obj client.Object, prefix string, setName func(name string)) error {
+	base := SanitizeName(obj.GetName(), prefix)
+	setName(base)
+	for attempt := 0; ; attempt++ {
+		err := c.Create(ctx, obj)
+		if err == nil || !k8sErrors.IsAlreadyExists(err) {
+			return err
+		}
+		if attempt == maxNameGenerationAttempts {
+			return fmt.Errorf("unable to generate a unique name for %q after %d attempts", base, maxNameGenerationAttempts)
+		}
+		setName(withRandomSuffix(base))
+	}
+}
+
+// withRandomSuffix appends a random suffix to name, trimming name so that the result is at most maxNameLength long
+func withRandomSuffix(name string) string {
+	if len(name)+nameSuffixLength+1 > maxNameLength {
+		name = strings.TrimRight(name[:maxNameLength-nameSuffixLength-1], "-")
+	}
+	return name + "-" + rand.String(nameSuffixLength)
+}
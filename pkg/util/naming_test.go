@@ -0,0 +1,153 @@
+//
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	appstudiov1alpha1 "github.com/konflux-ci/application-api/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		prefix string
+		want   string
+	}{
+		{name: "valid name is unchanged", in: "frontend", want: "frontend"},
+		{name: "upper case and invalid characters", in: "My Frontend_App", want: "my-frontend-app"},
+		{name: "leading digit", in: "2048", want: "comp-2048"},
+		{name: "only invalid characters", in: "___", want: "comp"},
+		{name: "too long", in: strings.Repeat("a", 70), want: strings.Repeat("a", 63)},
+		{name: "leading digit with another prefix", in: "2048", prefix: "app", want: "app-2048"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix := tt.prefix
+			if prefix == "" {
+				prefix = "comp"
+			}
+			got := SanitizeName(tt.in, prefix)
+			assert.Equal(t, tt.want, got)
+			assert.Empty(t, validation.IsDNS1035Label(got))
+		})
+	}
+}
+
+func TestGenerateUniqueName(t *testing.T) {
+	tests := []struct {
+		name       string
+		base       string
+		takenNames map[string]bool
+		existsErr  error
+		wantName   string
+		wantPrefix string
+		wantErr    string
+	}{
+		{
+			name:     "name is not taken",
+			base:     "My App",
+			wantName: "my-app",
+		},
+		{
+			name:       "name is taken",
+			base:       "my-app",
+			takenNames: map[string]bool{"my-app": true},
+			wantPrefix: "my-app-",
+		},
+		{
+			name:       "long name is trimmed to fit the suffix",
+			base:       strings.Repeat("a", 63),
+			takenNames: map[string]bool{strings.Repeat("a", 63): true},
+			wantPrefix: strings.Repeat("a", 57) + "-",
+		},
+		{
+			name:      "error checking the name",
+			base:      "my-app",
+			existsErr: errors.New("some error"),
+			wantErr:   "some error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, err := GenerateUniqueName(tt.base, "app", func(name string) (bool, error) {
+				return tt.takenNames[name], tt.existsErr
+			})
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			if tt.wantName != "" {
+				assert.Equal(t, tt.wantName, name)
+			} else {
+				assert.True(t, strings.HasPrefix(name, tt.wantPrefix), "expected name with prefix %s, got %s", tt.wantPrefix, name)
+				assert.Len(t, name, len(tt.wantPrefix)+nameSuffixLength)
+			}
+			assert.Empty(t, validation.IsDNS1035Label(name))
+		})
+	}
+
+	t.Run("gives up when every name is taken", func(t *testing.T) {
+		_, err := GenerateUniqueName("my-app", "app", func(name string) (bool, error) {
+			return true, nil
+		})
+		assert.ErrorContains(t, err, "unable to generate a unique name")
+	})
+}
+
+func TestCreateWithUniqueName(t *testing.T) {
+	s := scheme.Scheme
+	err := appstudiov1alpha1.AddToScheme(s)
+	require.NoError(t, err)
+
+	existing := appstudiov1alpha1.Component{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-component", Namespace: "default"},
+		Spec:       appstudiov1alpha1.ComponentSpec{ComponentName: "my-component"},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithObjects(&existing).Build()
+
+	for _, wantPrefix := range []string{"new-component", "my-component-"} {
+		base := strings.TrimSuffix(wantPrefix, "-")
+		component := &appstudiov1alpha1.Component{
+			ObjectMeta: metav1.ObjectMeta{Name: base, Namespace: "default"},
+		}
+		err := CreateWithUniqueName(context.Background(), fakeClient, component, "comp", func(name string) {
+			component.Name = name
+			component.Spec.ComponentName = name
+		})
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(component.Name, wantPrefix), "expected name with prefix %s, got %s", wantPrefix, component.Name)
+
+		var created appstudiov1alpha1.Component
+		err = fakeClient.Get(context.Background(), client.ObjectKeyFromObject(component), &created)
+		require.NoError(t, err)
+		assert.Equal(t, created.Name, created.Spec.ComponentName)
+	}
+}
@@ -15,6 +15,8 @@
 
 package util
 
+import "strings"
+
 // StrInList returns true if the given string is present in strList
 func StrInList(str string, strList []string) bool {
 	for _, val := range strList {
@@ -34,3 +36,14 @@ func RemoveStrFromList(str string, strList []string) []string {
 	}
 	return strList
 }
+
+// SplitCommaSeparated returns the trimmed, non-empty values of the comma separated list str
+func SplitCommaSeparated(str string) []string {
+	var values []string
+	for _, val := range strings.Split(str, ",") {
+		if val = strings.TrimSpace(val); val != "" {
+			values = append(values, val)
+		}
+	}
+	return values
+}
@@ -87,3 +87,32 @@ func TestRemoveStrFromList(t *testing.T) {
 		}
 	}
 }
+
+func TestSplitCommaSeparated(t *testing.T) {
+	tests := []struct {
+		name string
+		str  string
+		want []string
+	}{
+		{
+			name: "empty string",
+			str:  "",
+			want: nil,
+		},
+		{
+			name: "single value",
+			str:  "test",
+			want: []string{"test"},
+		},
+		{
+			name: "values with whitespace and empty entries",
+			str:  " some, test ,,words,",
+			want: []string{"some", "test", "words"},
+		},
+	}
+
+	for _, tt := range tests {
+		values := SplitCommaSeparated(tt.str)
+		assert.Equal(t, tt.want, values, "TestSplitCommaSeparated(): unexpected values for %q", tt.str)
+	}
+}
@@ -21,8 +21,12 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"os"
+	"strings"
 
 	appstudiov1alpha1 "github.com/konflux-ci/application-api/api/v1alpha1"
+	"github.com/redhat-appstudio/application-service/pkg/featuregates"
+	"github.com/redhat-appstudio/application-service/pkg/labels"
 	"github.com/redhat-appstudio/application-service/pkg/util"
 
 	"github.com/go-logr/logr"
@@ -36,15 +40,29 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// AllowedImageRegistriesEnvVar is the environment variable containing the comma separated list of registries, or repository
+// prefixes such as quay.io/org/*, that Component container images must come from. If unset, any registry is allowed.
+const AllowedImageRegistriesEnvVar = "ALLOWED_IMAGE_REGISTRIES"
+
+// ContainerImageNotAllowedError is returned when a Component's container image isn't from one of the allowed registries
+const ContainerImageNotAllowedError = "container image %q is not from an allowed registry, it must match one of: %s"
+
 // log is for logging in this package.
 // Webhook describes the data structure for the release webhook
 type ComponentWebhook struct {
 	client client.Client
 	log    logr.Logger
+
+	// readOnly disables the updates the webhook makes to resources other than the one under admission
+	readOnly bool
+
+	// allowedRegistries, if set, restricts the container images Components may reference
+	allowedRegistries []string
 }
 
 func (w *ComponentWebhook) Register(mgr ctrl.Manager, log *logr.Logger) error {
 	w.client = mgr.GetClient()
+	w.allowedRegistries = util.SplitCommaSeparated(os.Getenv(AllowedImageRegistriesEnvVar))
 
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(&appstudiov1alpha1.Component{}).
@@ -63,18 +81,32 @@ func (r *ComponentWebhook) Default(ctx context.Context, obj runtime.Object) erro
 	compName := component.Name
 	componentlog := r.log.WithValues("controllerKind", "Component").WithValues("name", compName).WithValues("namespace", component.Namespace)
 
-	if len(component.OwnerReferences) == 0 && component.DeletionTimestamp.IsZero() {
-		// Get the Application CR
-		// Use the background context to ensure the operator's kubeconfig is used
-		hasApplication := appstudiov1alpha1.Application{}
-		err := r.client.Get(context.Background(), types.NamespacedName{Name: component.Spec.Application, Namespace: component.Namespace}, &hasApplication)
-		if err != nil {
+	if !component.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	// Get the Application CR
+	// Use the background context to ensure the operator's kubeconfig is used
+	hasApplication := appstudiov1alpha1.Application{}
+	appErr := r.client.Get(context.Background(), types.NamespacedName{Name: component.Spec.Application, Namespace: component.Namespace}, &hasApplication)
+	if featuregates.Default.Enabled(featuregates.ComponentOwnershipLabels) {
+		if appErr != nil {
+			labels.Apply(component, nil)
+		} else {
+			labels.Apply(component, &hasApplication)
+		}
+	}
+
+	if len(component.OwnerReferences) == 0 {
+		if appErr != nil {
 			// Don't block if the Application doesn't exist yet - this will retrigger whenever the resource is modified
-			err = fmt.Errorf("unable to get the Application %s for Component %s, ignoring for now", component.Spec.Application, compName)
+			err := fmt.Errorf("unable to get the Application %s for Component %s, ignoring for now", component.Spec.Application, compName)
 			componentlog.Error(err, "skip setting owner reference on component")
+		} else if r.skipWrite(componentlog, "setting owner reference on component", "application", hasApplication.Name) {
+			return nil
 		} else {
 			// Update the Component's owner ref's - retry on conflict
-			err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 				var curComp appstudiov1alpha1.Component
 				// Get the Component to update using the operator's kubeconfig so that there aren't any permissions issues setting the owner reference
 				// Use the background context to ensure the operator's kubeconfig is used
@@ -129,6 +161,9 @@ func (r *ComponentWebhook) UpdateNudgedComponentStatus(ctx context.Context, obj
 
 		// Add the component to the status if it's not already present
 		if !util.StrInList(compName, nudgedComp.Status.BuildNudgedBy) {
+			if r.skipWrite(componentlog, "adding component to build-nudged-by status", "nudgedComponent", nudgedCompName) {
+				continue
+			}
 
 			// Update the Component's status - retry on conflict
 			err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
@@ -175,6 +210,12 @@ func (r *ComponentWebhook) ValidateCreate(ctx context.Context, obj runtime.Objec
 		sourceSpecified = true
 	}
 
+	if comp.Spec.ContainerImage != "" {
+		if err := validateContainerImage(comp.Spec.ContainerImage, r.allowedRegistries); err != nil {
+			return err
+		}
+	}
+
 	if !sourceSpecified {
 		return errors.New(appstudiov1alpha1.MissingGitOrImageSource)
 	}
@@ -212,6 +253,11 @@ func (r *ComponentWebhook) ValidateUpdate(ctx context.Context, oldObj, newObj ru
 	if newComp.Spec.Source.GitSource != nil && oldComp.Spec.Source.GitSource != nil && (newComp.Spec.Source.GitSource.URL != oldComp.Spec.Source.GitSource.URL) {
 		return fmt.Errorf(appstudiov1alpha1.GitSourceUpdateError, *(newComp.Spec.Source.GitSource))
 	}
+	if newComp.Spec.ContainerImage != "" && newComp.Spec.ContainerImage != oldComp.Spec.ContainerImage {
+		if err := validateContainerImage(newComp.Spec.ContainerImage, r.allowedRegistries); err != nil {
+			return err
+		}
+	}
 	if len(newComp.Spec.BuildNudgesRef) != 0 {
 		err := r.validateBuildNudgesRefGraph(ctx, newComp.Spec.BuildNudgesRef, newComp.Namespace, newComp.Name)
 		if err != nil {
@@ -237,6 +283,9 @@ func (r *ComponentWebhook) ValidateDelete(ctx context.Context, obj runtime.Objec
 
 	// Check which Components this component nudges. Update their statuses to remove the component
 	for _, nudgedComponentName := range comp.Spec.BuildNudgesRef {
+		if r.skipWrite(componentlog, "removing component from build-nudged-by status", "nudgedComponent", nudgedComponentName) {
+			continue
+		}
 		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 			nudgedComponent := &appstudiov1alpha1.Component{}
 			err := r.client.Get(ctx, types.NamespacedName{Namespace: componentNamespace, Name: nudgedComponentName}, nudgedComponent)
@@ -257,6 +306,9 @@ func (r *ComponentWebhook) ValidateDelete(ctx context.Context, obj runtime.Objec
 
 	// Next, loop through the Component's list of nudging components, and update their specs
 	for _, nudgedComponentName := range comp.Status.BuildNudgedBy {
+		if r.skipWrite(componentlog, "removing component from build-nudges-ref", "nudgingComponent", nudgedComponentName) {
+			continue
+		}
 		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 			nudgingComponent := &appstudiov1alpha1.Component{}
 			err := r.client.Get(ctx, types.NamespacedName{Namespace: componentNamespace, Name: nudgedComponentName}, nudgingComponent)
@@ -275,6 +327,15 @@ func (r *ComponentWebhook) ValidateDelete(ctx context.Context, obj runtime.Objec
 	return nil
 }
 
+// skipWrite returns true if the webhook is in read-only mode, logging the write that would otherwise have been made
+func (r *ComponentWebhook) skipWrite(log logr.Logger, action string, keysAndValues ...interface{}) bool {
+	if !r.readOnly {
+		return false
+	}
+	log.Info("read-only mode, skipping "+action, keysAndValues...)
+	return true
+}
+
 // validateBuildNudgesRefGraph returns an error if a cycle was found in the 'build-nudges-ref' dependency graph
 // If no cycle is found, it returns nil
 func (r *ComponentWebhook) validateBuildNudgesRefGraph(ctx context.Context, nudgedComponentNames []string, componentNamespace string, componentName string) error {
@@ -300,3 +361,40 @@ func (r *ComponentWebhook) validateBuildNudgesRefGraph(ctx context.Context, nudg
 
 	return nil
 }
+
+// validateContainerImage returns an error if allowedRegistries is set and image doesn't match any of its entries. An entry
+// matches images from the registry or repository prefix it names, with an optional trailing "/*".
+func validateContainerImage(image string, allowedRegistries []string) error {
+	if len(allowedRegistries) == 0 {
+		return nil
+	}
+	repository := imageRepository(image)
+	for _, allowed := range allowedRegistries {
+		allowed = strings.TrimSuffix(strings.TrimSuffix(allowed, "*"), "/")
+		if repository == allowed || strings.HasPrefix(repository, allowed+"/") {
+			return nil
+		}
+	}
+	return fmt.Errorf(ContainerImageNotAllowedError, image, strings.Join(allowedRegistries, ", "))
+}
+
+// imageRepository returns the repository of an image reference, without its tag or digest. Docker Hub references are
+// normalized as container runtimes do: images without a registry host are expanded to docker.io, index.docker.io is
+// replaced with docker.io, and single segment docker.io repositories are expanded to library/.
+func imageRepository(image string) string {
+	repository, _, _ := strings.Cut(image, "@")
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository = repository[:i]
+	}
+
+	host, path, found := strings.Cut(repository, "/")
+	if !found || (!strings.ContainsAny(host, ".:") && host != "localhost") {
+		host, path = "docker.io", repository
+	} else if host == "index.docker.io" {
+		host = "docker.io"
+	}
+	if host == "docker.io" && !strings.Contains(path, "/") {
+		path = "library/" + path
+	}
+	return host + "/" + path
+}
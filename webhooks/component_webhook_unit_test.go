@@ -21,6 +21,8 @@ import (
 	"testing"
 
 	appstudiov1alpha1 "github.com/konflux-ci/application-api/api/v1alpha1"
+	"github.com/redhat-appstudio/application-service/pkg/featuregates"
+	"github.com/redhat-appstudio/application-service/pkg/labels"
 	"github.com/redhat-appstudio/application-service/pkg/util"
 	"go.uber.org/zap/zapcore"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -122,6 +124,117 @@ func TestComponentDefaultingWebhook(t *testing.T) {
 	}
 }
 
+func TestComponentDefaultingWebhookLabels(t *testing.T) {
+
+	fakeClient := setUpComponents(t)
+
+	app := appstudiov1alpha1.Application{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "labelled-application",
+			Namespace: "default",
+			Annotations: map[string]string{
+				labels.TeamLabel:  "team-a",
+				labels.OwnerLabel: "invalid owner value",
+			},
+		},
+		Spec: appstudiov1alpha1.ApplicationSpec{
+			DisplayName: "app",
+		},
+	}
+	err := fakeClient.Create(context.Background(), &app)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		comp       appstudiov1alpha1.Component
+		wantLabels map[string]string
+	}{
+		{
+			name: "labels are copied from the application",
+			comp: appstudiov1alpha1.Component{
+				ObjectMeta: v1.ObjectMeta{
+					Name:      "labelled-component",
+					Namespace: "default",
+					Labels: map[string]string{
+						"custom": "label",
+					},
+				},
+				Spec: appstudiov1alpha1.ComponentSpec{
+					ComponentName: "labelled-component",
+					Application:   "labelled-application",
+				},
+			},
+			wantLabels: map[string]string{
+				"custom":                "label",
+				labels.ApplicationLabel: "labelled-application",
+				labels.PartOfLabel:      "labelled-application",
+				labels.TeamLabel:        "team-a",
+			},
+		},
+		{
+			name: "application labels are set even if the application is not found",
+			comp: appstudiov1alpha1.Component{
+				ObjectMeta: v1.ObjectMeta{
+					Name:      "component-without-application",
+					Namespace: "default",
+				},
+				Spec: appstudiov1alpha1.ComponentSpec{
+					ComponentName: "component-without-application",
+					Application:   "application-not-found",
+				},
+			},
+			wantLabels: map[string]string{
+				labels.ApplicationLabel: "application-not-found",
+				labels.PartOfLabel:      "application-not-found",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			compWebhook := ComponentWebhook{
+				client: fakeClient,
+				log: zap.New(zap.UseFlagOptions(&zap.Options{
+					Development: true,
+					TimeEncoder: zapcore.ISO8601TimeEncoder,
+				})),
+			}
+			err := compWebhook.Default(context.Background(), &test.comp)
+			assert.Nil(t, err)
+			assert.Equal(t, test.wantLabels, test.comp.Labels)
+		})
+	}
+}
+
+func TestComponentDefaultingWebhookLabelsFeatureGate(t *testing.T) {
+	err := featuregates.Default.Set(string(featuregates.ComponentOwnershipLabels) + "=false")
+	require.NoError(t, err)
+	defer func() {
+		err := featuregates.Default.Set(string(featuregates.ComponentOwnershipLabels) + "=true")
+		require.NoError(t, err)
+	}()
+
+	compWebhook := ComponentWebhook{
+		client: setUpComponents(t),
+		log: zap.New(zap.UseFlagOptions(&zap.Options{
+			Development: true,
+			TimeEncoder: zapcore.ISO8601TimeEncoder,
+		})),
+	}
+	comp := appstudiov1alpha1.Component{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "unlabelled-component",
+			Namespace: "default",
+		},
+		Spec: appstudiov1alpha1.ComponentSpec{
+			ComponentName: "unlabelled-component",
+			Application:   "application1",
+		},
+	}
+	err = compWebhook.Default(context.Background(), &comp)
+	assert.Nil(t, err)
+	assert.Empty(t, comp.Labels)
+}
+
 func TestComponentCreateValidatingWebhook(t *testing.T) {
 
 	fakeClient := setUpComponents(t)
@@ -734,6 +847,140 @@ func TestUpdateNudgedComponentStatus(t *testing.T) {
 	}
 }
 
+func TestComponentWebhookReadOnly(t *testing.T) {
+	fakeClient := setUpComponents(t)
+
+	app := appstudiov1alpha1.Application{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "application1",
+			Namespace: "default",
+		},
+		Spec: appstudiov1alpha1.ApplicationSpec{
+			DisplayName: "app",
+		},
+	}
+	err := fakeClient.Create(context.Background(), &app)
+	require.NoError(t, err)
+
+	compWebhook := ComponentWebhook{
+		client: fakeClient,
+		log: zap.New(zap.UseFlagOptions(&zap.Options{
+			Development: true,
+			TimeEncoder: zapcore.ISO8601TimeEncoder,
+		})),
+		readOnly: true,
+	}
+
+	// The owner reference must not be set on the stored component
+	component := &appstudiov1alpha1.Component{}
+	err = fakeClient.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "component1"}, component)
+	require.NoError(t, err)
+	err = compWebhook.Default(context.Background(), component)
+	assert.Nil(t, err)
+	var storedComp appstudiov1alpha1.Component
+	err = fakeClient.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "component1"}, &storedComp)
+	require.NoError(t, err)
+	assert.Empty(t, storedComp.OwnerReferences)
+
+	// The nudged component's status must not be updated
+	err = compWebhook.UpdateNudgedComponentStatus(context.Background(), component)
+	assert.Nil(t, err)
+	var nudgedComp appstudiov1alpha1.Component
+	err = fakeClient.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "component2"}, &nudgedComp)
+	require.NoError(t, err)
+	assert.NotContains(t, nudgedComp.Status.BuildNudgedBy, "component1")
+
+	// Deleting a nudged component must not update the spec of the components nudging it
+	nudgedComp.Status.BuildNudgedBy = []string{"component1"}
+	err = compWebhook.ValidateDelete(context.Background(), &nudgedComp)
+	assert.Nil(t, err)
+	err = fakeClient.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "component1"}, &storedComp)
+	require.NoError(t, err)
+	assert.Contains(t, storedComp.Spec.BuildNudgesRef, "component2")
+}
+
+func TestValidateContainerImage(t *testing.T) {
+	allowedRegistries := []string{"quay.io/org/*", "registry.internal:5000", "docker.io/library"}
+
+	tests := []struct {
+		name              string
+		image             string
+		allowedRegistries []string
+		err               string
+	}{
+		{
+			name:  "no allowlist set",
+			image: "ghcr.io/someone/image:latest",
+		},
+		{
+			name:              "image from an allowed repository prefix",
+			image:             "quay.io/org/image:v1",
+			allowedRegistries: allowedRegistries,
+		},
+		{
+			name:              "image from an allowed registry, with a digest",
+			image:             "registry.internal:5000/team/image@sha256:0123456789abcdef",
+			allowedRegistries: allowedRegistries,
+		},
+		{
+			name:              "short image name expanded to docker.io",
+			image:             "nginx:latest",
+			allowedRegistries: allowedRegistries,
+		},
+		{
+			name:              "docker.io official image expanded to library",
+			image:             "docker.io/nginx:latest",
+			allowedRegistries: []string{"docker.io/library/*"},
+		},
+		{
+			name:              "index.docker.io normalized to docker.io",
+			image:             "index.docker.io/library/nginx@sha256:0123456789abcdef",
+			allowedRegistries: []string{"docker.io/library/*"},
+		},
+		{
+			name:              "index.docker.io official image expanded to library",
+			image:             "index.docker.io/nginx",
+			allowedRegistries: []string{"docker.io/library/*"},
+		},
+		{
+			name:              "image from another organization on an allowed registry",
+			image:             "quay.io/organization/image:v1",
+			allowedRegistries: allowedRegistries,
+			err:               "container image \"quay.io/organization/image:v1\" is not from an allowed registry",
+		},
+		{
+			name:              "docker.io image outside of the allowed repository prefix",
+			image:             "someone/image",
+			allowedRegistries: allowedRegistries,
+			err:               "container image \"someone/image\" is not from an allowed registry",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateContainerImage(test.image, test.allowedRegistries)
+			if test.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.ErrorContains(t, err, test.err)
+			}
+		})
+	}
+}
+
+func TestEnabledWebhooksReadOnly(t *testing.T) {
+	for _, readOnly := range []bool{true, false} {
+		var componentWebhook *ComponentWebhook
+		for _, w := range EnabledWebhooks(Options{ReadOnly: readOnly}) {
+			if w, ok := w.(*ComponentWebhook); ok {
+				componentWebhook = w
+			}
+		}
+		require.NotNil(t, componentWebhook)
+		assert.Equal(t, readOnly, componentWebhook.readOnly)
+	}
+}
+
 // setUpComponentsForFakeErrorClient creates a fake controller-runtime Kube client with components to test error scenarios
 func setUpComponentsForFakeErrorClient(t *testing.T) *FakeClient {
 	fakeErrorClient := NewFakeErrorClient(t)
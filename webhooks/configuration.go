@@ -0,0 +1,249 @@
+//
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/redhat-appstudio/application-service/pkg/util"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// namespaceNameLabel is the label set by Kubernetes on every namespace, containing the namespace's name
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+// webhookPathInfix identifies the webhooks served by application-service, based on the paths they are registered on
+const webhookPathInfix = "-appstudio-redhat-com-v1alpha1-"
+
+// ExcludedNamespacesAnnotation records, on the webhook configurations, the comma separated namespaces application-service
+// excluded from the webhooks, identifying the namespace selector term it owns
+const ExcludedNamespacesAnnotation = "appstudio.redhat.com/webhook-excluded-namespaces"
+
+const (
+	// DefaultMutatingConfigurationName is the name of the application-service MutatingWebhookConfiguration, as deployed from config/default
+	DefaultMutatingConfigurationName = "application-service-mutating-webhook-configuration"
+
+	// DefaultValidatingConfigurationName is the name of the application-service ValidatingWebhookConfiguration, as deployed from config/default
+	DefaultValidatingConfigurationName = "application-service-validating-webhook-configuration"
+)
+
+//+kubebuilder:rbac:groups=admissionregistration.k8s.io,resources=mutatingwebhookconfigurations,verbs=get;update,resourceNames=application-service-mutating-webhook-configuration
+//+kubebuilder:rbac:groups=admissionregistration.k8s.io,resources=validatingwebhookconfigurations,verbs=get;update,resourceNames=application-service-validating-webhook-configuration
+
+// ConfigurationOptions describes the settings applied to the application-service webhook configurations at startup
+type ConfigurationOptions struct {
+	// FailurePolicy, if set, replaces the failure policy of every application-service webhook
+	FailurePolicy *admissionregistrationv1.FailurePolicyType
+
+	// ExcludedNamespaces are namespaces whose requests are never sent to the application-service webhooks
+	ExcludedNamespaces []string
+
+	// MutatingConfigurationName is the name of the application-service MutatingWebhookConfiguration. Defaults to
+	// DefaultMutatingConfigurationName.
+	MutatingConfigurationName string
+
+	// ValidatingConfigurationName is the name of the application-service ValidatingWebhookConfiguration. Defaults to
+	// DefaultValidatingConfigurationName.
+	ValidatingConfigurationName string
+}
+
+// ParseConfigurationOptions parses the webhook failure policy (Fail or Ignore, empty to leave unchanged) and the comma
+// separated list of namespaces to exclude from the webhooks
+func ParseConfigurationOptions(failurePolicy, excludedNamespaces string) (ConfigurationOptions, error) {
+	opts := ConfigurationOptions{}
+
+	switch policy := admissionregistrationv1.FailurePolicyType(strings.TrimSpace(failurePolicy)); policy {
+	case "":
+	case admissionregistrationv1.Fail, admissionregistrationv1.Ignore:
+		opts.FailurePolicy = &policy
+	default:
+		return opts, fmt.Errorf("invalid webhook failure policy %q: must be one of %q or %q", failurePolicy, admissionregistrationv1.Fail, admissionregistrationv1.Ignore)
+	}
+
+	opts.ExcludedNamespaces = util.SplitCommaSeparated(excludedNamespaces)
+
+	return opts, nil
+}
+
+// IsEmpty returns true if the options don't change the webhook configurations
+func (o ConfigurationOptions) IsEmpty() bool {
+	return o.FailurePolicy == nil && len(o.ExcludedNamespaces) == 0
+}
+
+// ApplyConfigurationOptions updates the failure policy and namespace selector of the application-service webhooks in the
+// application-service mutating and validating webhook configurations. Both configurations are updated, even if updating
+// the other one fails, and only if they differ from the options. The namespace exclusion previously applied is recorded
+// in the ExcludedNamespacesAnnotation of each configuration, so that it is removed when the option is unset.
+func ApplyConfigurationOptions(ctx context.Context, c client.Client, log logr.Logger, opts ConfigurationOptions) error {
+	mutatingName := opts.MutatingConfigurationName
+	if mutatingName == "" {
+		mutatingName = DefaultMutatingConfigurationName
+	}
+	validatingName := opts.ValidatingConfigurationName
+	if validatingName == "" {
+		validatingName = DefaultValidatingConfigurationName
+	}
+
+	return errors.Join(
+		applyToConfiguration(ctx, c, log, opts, "MutatingWebhookConfiguration", mutatingName, func() client.Object {
+			return &admissionregistrationv1.MutatingWebhookConfiguration{}
+		}),
+		applyToConfiguration(ctx, c, log, opts, "ValidatingWebhookConfiguration", validatingName, func() client.Object {
+			return &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		}),
+	)
+}
+
+// webhookSettings points at the fields of a mutating or validating webhook that the options apply to
+type webhookSettings struct {
+	clientConfig      admissionregistrationv1.WebhookClientConfig
+	failurePolicy     **admissionregistrationv1.FailurePolicyType
+	namespaceSelector **metav1.LabelSelector
+}
+
+// webhookSettingsOf returns the settings of every webhook of a mutating or validating webhook configuration
+func webhookSettingsOf(config client.Object) []webhookSettings {
+	var settings []webhookSettings
+	switch config := config.(type) {
+	case *admissionregistrationv1.MutatingWebhookConfiguration:
+		for i := range config.Webhooks {
+			webhook := &config.Webhooks[i]
+			settings = append(settings, webhookSettings{webhook.ClientConfig, &webhook.FailurePolicy, &webhook.NamespaceSelector})
+		}
+	case *admissionregistrationv1.ValidatingWebhookConfiguration:
+		for i := range config.Webhooks {
+			webhook := &config.Webhooks[i]
+			settings = append(settings, webhookSettings{webhook.ClientConfig, &webhook.FailurePolicy, &webhook.NamespaceSelector})
+		}
+	}
+	return settings
+}
+
+// applyToConfiguration applies the options to the application-service webhooks of the webhook configuration of the given
+// kind and name, created by newConfig, and updates it if anything changed. A missing configuration is only an error if there are options to apply.
+func applyToConfiguration(ctx context.Context, c client.Client, log logr.Logger, opts ConfigurationOptions, kind, name string, newConfig func() client.Object) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		config := newConfig()
+		if err := c.Get(ctx, client.ObjectKey{Name: name}, config); err != nil {
+			if k8sErrors.IsNotFound(err) && opts.IsEmpty() {
+				return nil
+			}
+			return err
+		}
+
+		previouslyExcluded := util.SplitCommaSeparated(config.GetAnnotations()[ExcludedNamespacesAnnotation])
+		changed := false
+		for _, webhook := range webhookSettingsOf(config) {
+			if isApplicationServiceWebhook(webhook.clientConfig) {
+				var webhookChanged bool
+				*webhook.failurePolicy, *webhook.namespaceSelector, webhookChanged = applyOptions(opts, previouslyExcluded, *webhook.failurePolicy, *webhook.namespaceSelector)
+				changed = changed || webhookChanged
+			}
+		}
+
+		annotations := config.GetAnnotations()
+		if excluded := strings.Join(opts.ExcludedNamespaces, ","); excluded != annotations[ExcludedNamespacesAnnotation] {
+			if excluded == "" {
+				delete(annotations, ExcludedNamespacesAnnotation)
+			} else {
+				if annotations == nil {
+					annotations = make(map[string]string)
+				}
+				annotations[ExcludedNamespacesAnnotation] = excluded
+			}
+			config.SetAnnotations(annotations)
+			changed = true
+		}
+
+		if !changed {
+			return nil
+		}
+		log.Info("updating webhook configuration", "kind", kind, "name", name)
+		return c.Update(ctx, config)
+	})
+}
+
+// isApplicationServiceWebhook returns true if the webhook's client config points at one of the application-service webhook paths
+func isApplicationServiceWebhook(clientConfig admissionregistrationv1.WebhookClientConfig) bool {
+	if clientConfig.Service != nil && clientConfig.Service.Path != nil {
+		return strings.Contains(*clientConfig.Service.Path, webhookPathInfix)
+	}
+	if clientConfig.URL != nil {
+		return strings.Contains(*clientConfig.URL, webhookPathInfix)
+	}
+	return false
+}
+
+// applyOptions returns the failure policy and namespace selector of a webhook with the options applied, and whether they
+// differ from the current ones. The namespace exclusion term previously applied by application-service, excluding exactly
+// the previouslyExcluded namespaces, is replaced, or removed if no namespaces are excluded anymore. Other selector terms,
+// including namespace exclusions written by others, are kept.
+func applyOptions(opts ConfigurationOptions, previouslyExcluded []string, failurePolicy *admissionregistrationv1.FailurePolicyType, selector *metav1.LabelSelector) (*admissionregistrationv1.FailurePolicyType, *metav1.LabelSelector, bool) {
+	newFailurePolicy := failurePolicy
+	if opts.FailurePolicy != nil {
+		policy := *opts.FailurePolicy
+		newFailurePolicy = &policy
+	}
+
+	newSelector := selector
+	if len(opts.ExcludedNamespaces) != 0 || len(previouslyExcluded) != 0 {
+		if selector == nil {
+			newSelector = &metav1.LabelSelector{}
+		} else {
+			newSelector = selector.DeepCopy()
+		}
+		var expressions []metav1.LabelSelectorRequirement
+		for _, expression := range newSelector.MatchExpressions {
+			if isExclusionTerm(expression, previouslyExcluded) {
+				continue
+			}
+			expressions = append(expressions, expression)
+		}
+		if len(opts.ExcludedNamespaces) != 0 {
+			expressions = append(expressions, exclusionTerm(opts.ExcludedNamespaces))
+		}
+		newSelector.MatchExpressions = expressions
+		if selector == nil && len(expressions) == 0 {
+			newSelector = nil
+		}
+	}
+
+	changed := !equality.Semantic.DeepEqual(failurePolicy, newFailurePolicy) || !equality.Semantic.DeepEqual(selector, newSelector)
+	return newFailurePolicy, newSelector, changed
+}
+
+// exclusionTerm returns the namespace selector term excluding the namespaces
+func exclusionTerm(namespaces []string) metav1.LabelSelectorRequirement {
+	return metav1.LabelSelectorRequirement{
+		Key:      namespaceNameLabel,
+		Operator: metav1.LabelSelectorOpNotIn,
+		Values:   namespaces,
+	}
+}
+
+// isExclusionTerm returns true if the selector term excludes exactly the namespaces
+func isExclusionTerm(expression metav1.LabelSelectorRequirement, namespaces []string) bool {
+	return len(namespaces) != 0 && equality.Semantic.DeepEqual(expression, exclusionTerm(namespaces))
+}
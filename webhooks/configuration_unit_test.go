@@ -0,0 +1,292 @@
+//
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestParseConfigurationOptions(t *testing.T) {
+	ignore := admissionregistrationv1.Ignore
+
+	tests := []struct {
+		name               string
+		failurePolicy      string
+		excludedNamespaces string
+		want               ConfigurationOptions
+		err                string
+	}{
+		{
+			name: "nothing set",
+			want: ConfigurationOptions{},
+		},
+		{
+			name:               "failure policy and excluded namespaces set",
+			failurePolicy:      "Ignore",
+			excludedNamespaces: "kube-system, openshift-operators,,",
+			want: ConfigurationOptions{
+				FailurePolicy:      &ignore,
+				ExcludedNamespaces: []string{"kube-system", "openshift-operators"},
+			},
+		},
+		{
+			name:          "invalid failure policy",
+			failurePolicy: "ignore",
+			err:           "invalid webhook failure policy",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts, err := ParseConfigurationOptions(test.failurePolicy, test.excludedNamespaces)
+			if test.err == "" {
+				assert.Nil(t, err)
+				assert.Equal(t, test.want, opts)
+				assert.Equal(t, test.failurePolicy == "" && test.excludedNamespaces == "", opts.IsEmpty())
+			} else {
+				assert.Contains(t, err.Error(), test.err)
+			}
+		})
+	}
+}
+
+func TestApplyConfigurationOptions(t *testing.T) {
+	fail := admissionregistrationv1.Fail
+	ignore := admissionregistrationv1.Ignore
+	sideEffects := admissionregistrationv1.SideEffectClassNone
+	componentPath := "/mutate-appstudio-redhat-com-v1alpha1-component"
+	validateComponentPath := "/validate-appstudio-redhat-com-v1alpha1-component"
+	otherPath := "/mutate-some-other-resource"
+
+	mutatingConfig := admissionregistrationv1.MutatingWebhookConfiguration{
+		ObjectMeta: v1.ObjectMeta{Name: DefaultMutatingConfigurationName},
+		Webhooks: []admissionregistrationv1.MutatingWebhook{
+			{
+				Name:                    "mcomponent.kb.io",
+				AdmissionReviewVersions: []string{"v1"},
+				SideEffects:             &sideEffects,
+				FailurePolicy:           &fail,
+				ClientConfig: admissionregistrationv1.WebhookClientConfig{
+					Service: &admissionregistrationv1.ServiceReference{Name: "webhook-service", Namespace: "system", Path: &componentPath},
+				},
+				NamespaceSelector: &v1.LabelSelector{
+					MatchExpressions: []v1.LabelSelectorRequirement{
+						{Key: "tenant", Operator: v1.LabelSelectorOpExists},
+						{Key: namespaceNameLabel, Operator: v1.LabelSelectorOpNotIn, Values: []string{"old-namespace"}},
+					},
+				},
+			},
+		},
+	}
+	validatingConfig := admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: v1.ObjectMeta{Name: DefaultValidatingConfigurationName},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{
+			{
+				Name:                    "vcomponent.kb.io",
+				AdmissionReviewVersions: []string{"v1"},
+				SideEffects:             &sideEffects,
+				FailurePolicy:           &fail,
+				ClientConfig: admissionregistrationv1.WebhookClientConfig{
+					Service: &admissionregistrationv1.ServiceReference{Name: "webhook-service", Namespace: "system", Path: &validateComponentPath},
+				},
+			},
+		},
+	}
+	otherConfig := admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: v1.ObjectMeta{Name: "some-other-operator"},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{
+			{
+				Name:                    "vother.kb.io",
+				AdmissionReviewVersions: []string{"v1"},
+				SideEffects:             &sideEffects,
+				FailurePolicy:           &fail,
+				ClientConfig: admissionregistrationv1.WebhookClientConfig{
+					Service: &admissionregistrationv1.ServiceReference{Name: "other-service", Namespace: "other", Path: &otherPath},
+				},
+			},
+		},
+	}
+
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(&mutatingConfig, &validatingConfig, &otherConfig).Build()
+
+	opts := ConfigurationOptions{
+		FailurePolicy:      &ignore,
+		ExcludedNamespaces: []string{"kube-system"},
+	}
+	err := ApplyConfigurationOptions(context.Background(), fakeClient, logr.Discard(), opts)
+	require.NoError(t, err)
+
+	var updatedMutatingConfig admissionregistrationv1.MutatingWebhookConfiguration
+	err = fakeClient.Get(context.Background(), client.ObjectKeyFromObject(&mutatingConfig), &updatedMutatingConfig)
+	require.NoError(t, err)
+	webhook := updatedMutatingConfig.Webhooks[0]
+	assert.Equal(t, ignore, *webhook.FailurePolicy)
+	// The namespace exclusion written by an admin must be kept
+	assert.Equal(t, []v1.LabelSelectorRequirement{
+		{Key: "tenant", Operator: v1.LabelSelectorOpExists},
+		{Key: namespaceNameLabel, Operator: v1.LabelSelectorOpNotIn, Values: []string{"old-namespace"}},
+		{Key: namespaceNameLabel, Operator: v1.LabelSelectorOpNotIn, Values: []string{"kube-system"}},
+	}, webhook.NamespaceSelector.MatchExpressions)
+	assert.Equal(t, "kube-system", updatedMutatingConfig.Annotations[ExcludedNamespacesAnnotation])
+
+	var updatedValidatingConfig admissionregistrationv1.ValidatingWebhookConfiguration
+	err = fakeClient.Get(context.Background(), client.ObjectKeyFromObject(&validatingConfig), &updatedValidatingConfig)
+	require.NoError(t, err)
+	assert.Equal(t, ignore, *updatedValidatingConfig.Webhooks[0].FailurePolicy)
+	assert.Equal(t, []v1.LabelSelectorRequirement{
+		{Key: namespaceNameLabel, Operator: v1.LabelSelectorOpNotIn, Values: []string{"kube-system"}},
+	}, updatedValidatingConfig.Webhooks[0].NamespaceSelector.MatchExpressions)
+
+	// Applying the same options again must not update the configurations
+	err = ApplyConfigurationOptions(context.Background(), fakeClient, logr.Discard(), opts)
+	require.NoError(t, err)
+	var unchangedMutatingConfig admissionregistrationv1.MutatingWebhookConfiguration
+	err = fakeClient.Get(context.Background(), client.ObjectKeyFromObject(&mutatingConfig), &unchangedMutatingConfig)
+	require.NoError(t, err)
+	assert.Equal(t, updatedMutatingConfig.ResourceVersion, unchangedMutatingConfig.ResourceVersion)
+	var unchangedValidatingConfig admissionregistrationv1.ValidatingWebhookConfiguration
+	err = fakeClient.Get(context.Background(), client.ObjectKeyFromObject(&validatingConfig), &unchangedValidatingConfig)
+	require.NoError(t, err)
+	assert.Equal(t, updatedValidatingConfig.ResourceVersion, unchangedValidatingConfig.ResourceVersion)
+
+	// Webhooks of other operators must be left untouched
+	var updatedOtherConfig admissionregistrationv1.ValidatingWebhookConfiguration
+	err = fakeClient.Get(context.Background(), client.ObjectKeyFromObject(&otherConfig), &updatedOtherConfig)
+	require.NoError(t, err)
+	assert.Equal(t, fail, *updatedOtherConfig.Webhooks[0].FailurePolicy)
+	assert.Nil(t, updatedOtherConfig.Webhooks[0].NamespaceSelector)
+}
+
+func TestApplyConfigurationOptionsExcludedNamespacesChanged(t *testing.T) {
+	componentPath := "/validate-appstudio-redhat-com-v1alpha1-component"
+	adminTerm := v1.LabelSelectorRequirement{Key: namespaceNameLabel, Operator: v1.LabelSelectorOpNotIn, Values: []string{"admin-namespace"}}
+	newConfig := func() *admissionregistrationv1.ValidatingWebhookConfiguration {
+		sideEffects := admissionregistrationv1.SideEffectClassNone
+		return &admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: v1.ObjectMeta{
+				Name:        DefaultValidatingConfigurationName,
+				Annotations: map[string]string{ExcludedNamespacesAnnotation: "kube-system,openshift-operators"},
+			},
+			Webhooks: []admissionregistrationv1.ValidatingWebhook{
+				{
+					Name:                    "vcomponent.kb.io",
+					AdmissionReviewVersions: []string{"v1"},
+					SideEffects:             &sideEffects,
+					ClientConfig: admissionregistrationv1.WebhookClientConfig{
+						Service: &admissionregistrationv1.ServiceReference{Name: "webhook-service", Namespace: "system", Path: &componentPath},
+					},
+					NamespaceSelector: &v1.LabelSelector{
+						MatchExpressions: []v1.LabelSelectorRequirement{
+							adminTerm,
+							{Key: namespaceNameLabel, Operator: v1.LabelSelectorOpNotIn, Values: []string{"kube-system", "openshift-operators"}},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name               string
+		excludedNamespaces []string
+		wantExpressions    []v1.LabelSelectorRequirement
+		wantAnnotation     string
+	}{
+		{
+			name:               "exclusion replaced",
+			excludedNamespaces: []string{"kube-system"},
+			wantExpressions: []v1.LabelSelectorRequirement{
+				adminTerm,
+				{Key: namespaceNameLabel, Operator: v1.LabelSelectorOpNotIn, Values: []string{"kube-system"}},
+			},
+			wantAnnotation: "kube-system",
+		},
+		{
+			name:            "exclusion removed when unset",
+			wantExpressions: []v1.LabelSelectorRequirement{adminTerm},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := newConfig()
+			mutatingConfig := &admissionregistrationv1.MutatingWebhookConfiguration{ObjectMeta: v1.ObjectMeta{Name: DefaultMutatingConfigurationName}}
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(config, mutatingConfig).Build()
+
+			err := ApplyConfigurationOptions(context.Background(), fakeClient, logr.Discard(), ConfigurationOptions{
+				ExcludedNamespaces: test.excludedNamespaces,
+			})
+			require.NoError(t, err)
+
+			var updatedConfig admissionregistrationv1.ValidatingWebhookConfiguration
+			err = fakeClient.Get(context.Background(), client.ObjectKeyFromObject(config), &updatedConfig)
+			require.NoError(t, err)
+			assert.Equal(t, test.wantExpressions, updatedConfig.Webhooks[0].NamespaceSelector.MatchExpressions)
+			assert.Equal(t, test.wantAnnotation, updatedConfig.Annotations[ExcludedNamespacesAnnotation])
+		})
+	}
+}
+
+func TestApplyConfigurationOptionsMissingConfiguration(t *testing.T) {
+	ignore := admissionregistrationv1.Ignore
+	fail := admissionregistrationv1.Fail
+	sideEffects := admissionregistrationv1.SideEffectClassNone
+	componentPath := "/validate-appstudio-redhat-com-v1alpha1-component"
+	validatingConfig := admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: v1.ObjectMeta{Name: DefaultValidatingConfigurationName},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{
+			{
+				Name:                    "vcomponent.kb.io",
+				AdmissionReviewVersions: []string{"v1"},
+				SideEffects:             &sideEffects,
+				FailurePolicy:           &fail,
+				ClientConfig: admissionregistrationv1.WebhookClientConfig{
+					Service: &admissionregistrationv1.ServiceReference{Name: "webhook-service", Namespace: "system", Path: &componentPath},
+				},
+			},
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(&validatingConfig).Build()
+
+	// Nothing to remove from a missing configuration if no options are set
+	err := ApplyConfigurationOptions(context.Background(), fakeClient, logr.Discard(), ConfigurationOptions{
+		MutatingConfigurationName: "renamed-mutating-webhook-configuration",
+	})
+	require.NoError(t, err)
+
+	// The validating configuration must still be updated if the mutating configuration is missing
+	opts := ConfigurationOptions{
+		FailurePolicy:             &ignore,
+		MutatingConfigurationName: "renamed-mutating-webhook-configuration",
+	}
+	err = ApplyConfigurationOptions(context.Background(), fakeClient, logr.Discard(), opts)
+	assert.ErrorContains(t, err, "renamed-mutating-webhook-configuration")
+
+	var updatedValidatingConfig admissionregistrationv1.ValidatingWebhookConfiguration
+	err = fakeClient.Get(context.Background(), client.ObjectKeyFromObject(&validatingConfig), &updatedValidatingConfig)
+	require.NoError(t, err)
+	assert.Equal(t, ignore, *updatedValidatingConfig.Webhooks[0].FailurePolicy)
+}
@@ -19,8 +19,17 @@ import (
 	"github.com/konflux-ci/operator-toolkit/webhook"
 )
 
-// EnabledWebhooks is a slice containing references to all the webhooks that have to be registered
-var EnabledWebhooks = []webhook.Webhook{
-	&ApplicationWebhook{},
-	&ComponentWebhook{},
+// Options configures the webhooks
+type Options struct {
+	// ReadOnly puts the webhooks in read-only safe mode. In that mode they still validate and mutate the objects under
+	// admission, but never write to other resources.
+	ReadOnly bool
+}
+
+// EnabledWebhooks returns references to all the webhooks that have to be registered, configured with opts
+func EnabledWebhooks(opts Options) []webhook.Webhook {
+	return []webhook.Webhook{
+		&ApplicationWebhook{},
+		&ComponentWebhook{readOnly: opts.ReadOnly},
+	}
 }